@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditRecord is one line of a --audit-log file: a compliance-oriented record
+// of a single completed role-binding operation, suitable for feeding into a
+// SIEM or similar pipeline. Unlike JournalEntry (which exists so a run can
+// resume itself), an audit log is write-only from this tool's perspective.
+type auditRecord struct {
+	Actor         string    `json:"actor"`
+	Action        string    `json:"action"`
+	SubjectEmail  string    `json:"subject_email,omitempty"`
+	Project       string    `json:"project"`
+	Role          string    `json:"role"`
+	PriorRole     string    `json:"prior_role,omitempty"`
+	Outcome       string    `json:"outcome"`
+	Error         string    `json:"error,omitempty"`
+	CorrelationID string    `json:"correlation_id"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// Actions recorded in auditRecord.Action. assign-role is recorded for role
+// assignments; remove-role is recorded for --reconcile deletions.
+const (
+	auditActionAssignRole = "assign-role"
+	auditActionRemoveRole = "remove-role"
+)
+
+// auditWriter appends auditRecord lines to a --audit-log file. A nil
+// *auditWriter is a no-op, matching journalWriter's nil-safety so callers can
+// pass one through unconditionally instead of branching on whether
+// --audit-log was set.
+type auditWriter struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// openAuditLog opens path for appending, creating it if needed. Audit
+// records are a durable compliance trail, not a per-run checkpoint like the
+// journal, so previous contents are always preserved.
+func openAuditLog(path string) (*auditWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log '%s': %w", path, err)
+	}
+	return &auditWriter{f: f}, nil
+}
+
+// record writes rec as a single JSON line.
+func (a *auditWriter) record(rec auditRecord) error {
+	if a == nil {
+		return nil
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.f.Write(line)
+	return err
+}
+
+// Close closes the underlying audit log file.
+func (a *auditWriter) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.f.Close()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nobl9/nobl9-go/manifest"
+	v1alphaRoleBinding "github.com/nobl9/nobl9-go/manifest/v1alpha/rolebinding"
+	"github.com/nobl9/nobl9-go/sdk"
+)
+
+// Sentinel errors returned (wrapped, via %w) by Backend methods and by the
+// higher-level assign*Role functions, so callers can branch on outcome with
+// errors.Is instead of matching error strings.
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrAlreadyAssigned = errors.New("role already assigned")
+)
+
+// Backend is the set of Nobl9 operations needed to assign and reconcile role
+// bindings. It exists so call sites depend on this behavior rather than on
+// *sdk.Client directly.
+//
+// APIBackend, which talks to the Nobl9 REST API through the nobl9-go SDK, is
+// the only implementation: this tool has never shelled out to sloctl for its
+// own operation (sloctl is only used by integration_test.go to provision
+// fixtures), so there is no second backend to abstract over yet. The
+// interface is kept narrow and here rather than speculatively adding a
+// sloctl-backed implementation with no real caller. --backend exists as a
+// flag (see backendAPI in main.go) but only accepts "api" today, so this
+// decision is surfaced to users instead of a --backend=sloctl option quietly
+// not existing.
+type Backend interface {
+	// LookupUser resolves userID (an email or username, per userIDMode) to a
+	// Nobl9 user ID, returning an error wrapping ErrUserNotFound if no such
+	// user exists.
+	LookupUser(ctx context.Context, userID, userIDMode string) (resolvedID string, err error)
+	// ListRoleBindings returns every role binding on the server.
+	ListRoleBindings(ctx context.Context) ([]v1alphaRoleBinding.RoleBinding, error)
+	// ApplyRoleBinding creates or updates rb.
+	ApplyRoleBinding(ctx context.Context, rb v1alphaRoleBinding.RoleBinding) error
+	// DeleteRoleBinding deletes the role binding named name.
+	DeleteRoleBinding(ctx context.Context, name string) error
+}
+
+// APIBackend implements Backend against the Nobl9 REST API via the nobl9-go
+// SDK client, which itself handles OAuth2 client-credentials auth
+// (NOBL9_CLIENT_ID/NOBL9_CLIENT_SECRET) and connection reuse. Retries for
+// transient errors are applied by retryErr (see retry.go) around LookupUser
+// and ApplyRoleBinding; ListRoleBindings and DeleteRoleBinding are not retried.
+type APIBackend struct {
+	client *sdk.Client
+}
+
+// NewAPIBackend wraps client as a Backend.
+func NewAPIBackend(client *sdk.Client) *APIBackend {
+	return &APIBackend{client: client}
+}
+
+func (b *APIBackend) LookupUser(ctx context.Context, userID, userIDMode string) (string, error) {
+	return resolveUserID(ctx, b.client, userID, userIDMode)
+}
+
+func (b *APIBackend) ListRoleBindings(ctx context.Context) ([]v1alphaRoleBinding.RoleBinding, error) {
+	start := time.Now()
+	objects, err := b.client.Objects().V1().Get(ctx, manifest.KindRoleBinding, nil, nil)
+	logger.Debug("backend call completed", "call", "list role bindings", "latency", time.Since(start))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list existing role bindings: %v", err)
+	}
+	bindings := make([]v1alphaRoleBinding.RoleBinding, 0, len(objects))
+	for _, obj := range objects {
+		if rb, ok := obj.(v1alphaRoleBinding.RoleBinding); ok {
+			bindings = append(bindings, rb)
+		}
+	}
+	return bindings, nil
+}
+
+func (b *APIBackend) ApplyRoleBinding(ctx context.Context, rb v1alphaRoleBinding.RoleBinding) error {
+	return retryErr(ctx, defaultRetryConfig, "apply role binding", func() error {
+		return b.client.Objects().V1().Apply(ctx, []manifest.Object{rb})
+	})
+}
+
+func (b *APIBackend) DeleteRoleBinding(ctx context.Context, name string) error {
+	start := time.Now()
+	roleBinding := v1alphaRoleBinding.New(v1alphaRoleBinding.Metadata{Name: name}, v1alphaRoleBinding.Spec{})
+	err := b.client.Objects().V1().Delete(ctx, []manifest.Object{roleBinding})
+	logger.Debug("backend call completed", "call", "delete role binding", "name", name, "latency", time.Since(start))
+	if err != nil {
+		return fmt.Errorf("failed to delete role binding '%s': %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// concurrencyOptions configures the worker pool used by processBulkAssignment.
+// rps of 0 means unlimited (no rate.Limiter is constructed); a burst below 1
+// is treated as 1.
+type concurrencyOptions struct {
+	workers int
+	rps     float64
+	burst   int
+}
+
+// newLimiter builds the shared token-bucket limiter for opts, or nil if no
+// rate limiting was requested.
+func (opts concurrencyOptions) newLimiter() *rate.Limiter {
+	if opts.rps <= 0 {
+		return nil
+	}
+	burst := opts.burst
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(opts.rps), burst)
+}
+
+// rowResult is the outcome of processing a single CSV row, handed back from a
+// worker to the aggregator in processBulkAssignment. bindingName is empty
+// unless a role binding was actually created (not on a dry run or a failure).
+// role is the row's effective role (its own Role column/field, or the CLI
+// --role default), since rows can override it individually. priorRole is set
+// when the row superseded an existing binding for a different role (see
+// supersedeExistingBinding), empty otherwise.
+type rowResult struct {
+	index       int
+	bindingName string
+	role        string
+	priorRole   string
+	err         error
+}
+
+// runWorkerPool dispatches rows across opts.workers goroutines, each calling
+// assignRoleBinding after acquiring a limiter token (if rate limiting is
+// enabled), replacing the old serial loop and fixed time.Sleep between calls.
+// Results are returned in row order once every row has been processed or ctx
+// is cancelled.
+// refreshBindingsEvery, if > 0, re-lists role bindings from the server after
+// every N completed rows. Since workers complete out of order, "every N" is
+// measured by completion count rather than dispatch index.
+// journal, if non-nil, gets one entry appended per completed row so a
+// --journal run can be resumed with ReplayJournal after an interruption.
+func runWorkerPool(
+	ctx context.Context,
+	backend Backend,
+	rows []CSVRow,
+	role string,
+	dryRun bool,
+	userIDMode string,
+	cache *roleBindingCache,
+	desired *desiredSet,
+	opts concurrencyOptions,
+	refreshBindingsEvery int,
+	journal *journalWriter,
+) []rowResult {
+	workers := opts.workers
+	if workers < 1 {
+		workers = 1
+	}
+	limiter := opts.newLimiter()
+
+	rowsCh := make(chan int)
+	resultsCh := make(chan rowResult, len(rows))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range rowsCh {
+				row := rows[i]
+				subjectID := row.Identifier(userIDMode)
+				subjectType := row.ResolvedSubjectType()
+				effectiveRole := row.Role
+				if effectiveRole == "" {
+					effectiveRole = role
+				}
+
+				if limiter != nil {
+					if err := limiter.Wait(ctx); err != nil {
+						resultsCh <- rowResult{index: i, err: err}
+						continue
+					}
+				}
+
+				logger.Info("processing row", "row", row.OriginalRow, "project", row.AppShortName, "subject_type", subjectType, "subject", subjectID, "role", effectiveRole)
+				bindingName, priorRole, err := assignRoleBinding(ctx, backend, row.AppShortName, subjectID, effectiveRole, dryRun, userIDMode, subjectType, cache, desired)
+				resultsCh <- rowResult{index: i, bindingName: bindingName, role: effectiveRole, priorRole: priorRole, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(rowsCh)
+		for i := range rows {
+			select {
+			case rowsCh <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(resultsCh)
+	}()
+
+	results := make([]rowResult, len(rows))
+	seen := make([]bool, len(rows))
+	completed, succeeded, failed := 0, 0, 0
+	start := time.Now()
+	showProgress := isTerminal(os.Stderr)
+	for res := range resultsCh {
+		results[res.index] = res
+		seen[res.index] = true
+		completed++
+		if res.err != nil {
+			failed++
+		} else {
+			succeeded++
+		}
+
+		if cache != nil && refreshBindingsEvery > 0 && completed%refreshBindingsEvery == 0 {
+			if err := cache.refresh(ctx, backend); err != nil {
+				logger.Warn("failed to refresh role binding cache", "error", err)
+			}
+		}
+
+		if journal != nil {
+			row := rows[res.index]
+			entry := JournalEntry{
+				RowIndex:  row.OriginalRow,
+				Project:   row.AppShortName,
+				Email:     row.Identifier(userIDMode),
+				Role:      res.role,
+				Timestamp: time.Now(),
+			}
+			switch {
+			case res.err == nil:
+				entry.Outcome = "assigned"
+			case errors.Is(res.err, ErrAlreadyAssigned):
+				entry.Outcome, entry.Error = "skipped", "already assigned"
+			default:
+				entry.Outcome, entry.Error = "failed", res.err.Error()
+			}
+			if err := journal.append(entry); err != nil {
+				logger.Warn("failed to write journal entry", "row", row.OriginalRow, "error", err)
+			}
+		}
+
+		if showProgress {
+			printProgress(os.Stderr, start, completed, len(rows), succeeded, failed)
+		}
+	}
+	if showProgress {
+		fmt.Fprintln(os.Stderr)
+	}
+	// Rows never dispatched (ctx cancelled before they were read off rowsCh)
+	// are reported as cancelled rather than silently left as a zero-value
+	// (nil-error) result.
+	for i, ok := range seen {
+		if !ok {
+			results[i] = rowResult{index: i, err: ctx.Err()}
+		}
+	}
+	return results
+}
+
+// isTerminal reports whether f looks like an interactive terminal rather
+// than a redirected file or pipe. Used to decide whether to render the live
+// progress line, which overwrites itself with carriage returns and would
+// otherwise clutter piped output or CI logs.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// printProgress renders a single self-overwriting progress line to out:
+// rows completed so far, throughput, an ETA for the remaining rows, and a
+// running success/fail count.
+func printProgress(out *os.File, start time.Time, completed, total, succeeded, failed int) {
+	elapsed := time.Since(start)
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(completed) / elapsed.Seconds()
+	}
+	eta := "unknown"
+	if rate > 0 {
+		eta = time.Duration(float64(total-completed) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	fmt.Fprintf(out, "\r%d/%d rows (%.1f rows/s, ETA %s) - %d ok, %d failed   ", completed, total, rate, eta, succeeded, failed)
+}
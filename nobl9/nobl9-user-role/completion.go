@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// sloctlBinEnv names the environment variable used to locate the sloctl
+// binary for --project completion, matching the SLOCTL_BIN variable
+// integration_test.go already uses to find sloctl for fixture setup.
+const sloctlBinEnv = "SLOCTL_BIN"
+
+// newCompletionCmd builds the `completion [bash|zsh|fish|powershell]`
+// subcommand, following the same kubectl/sloctl convention: the shell name is
+// its single argument, and the script is generated from the root command's
+// registered flags and completion functions.
+func newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion [bash|zsh|fish|powershell]",
+		Short: "Generate a shell completion script",
+		Long: `Generate a shell completion script for add-user-role, including
+dynamic completion of --role, --project (from 'sloctl get projects') and
+--csv file paths (.csv, .yaml, .yml and .json).
+
+To load completions:
+
+Bash:
+  $ source <(add-user-role completion bash)
+
+Zsh:
+  $ add-user-role completion zsh > "${fpath[1]}/_add-user-role"
+
+Fish:
+  $ add-user-role completion fish | source
+
+PowerShell:
+  PS> add-user-role completion powershell | Out-String | Invoke-Expression`,
+		DisableFlagsInUseLine: true,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(os.Stdout, true)
+			case "zsh":
+				return root.GenZshCompletion(os.Stdout)
+			case "fish":
+				return root.GenFishCompletion(os.Stdout, true)
+			default:
+				return root.GenPowerShellCompletionWithDesc(os.Stdout)
+			}
+		},
+	}
+}
+
+// registerCompletions wires up dynamic shell completion for flags whose valid
+// values don't fit cobra's static flag metadata: --role from validRoles,
+// --project from the live project list, and --csv from its supported file
+// extensions.
+func registerCompletions(cmd *cobra.Command) {
+	_ = cmd.RegisterFlagCompletionFunc("role", completeRoles)
+	_ = cmd.RegisterFlagCompletionFunc("project", completeProjects)
+	_ = cmd.MarkFlagFilename("csv", "csv", "yaml", "yml", "json")
+}
+
+// completeRoles completes --role from validRoles.
+func completeRoles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	roles := make([]string, 0, len(validRoles))
+	for role := range validRoles {
+		if strings.HasPrefix(role, toComplete) {
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+	return roles, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProjects completes --project by shelling out to `sloctl get
+// projects -A -o json` and reading back manifest names. sloctl isn't
+// otherwise a dependency of this tool (only integration_test.go shells out to
+// it, for fixture setup) - if it isn't on PATH or the call fails, completion
+// falls back to no suggestions rather than an error, since this is
+// best-effort UX, not something the command depends on to function.
+func completeProjects(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	sloctlBin := os.Getenv(sloctlBinEnv)
+	if sloctlBin == "" {
+		sloctlBin = "sloctl"
+	}
+
+	out, err := exec.Command(sloctlBin, "get", "projects", "-A", "-o", "json").Output()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var manifests []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(out, &manifests); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, m := range manifests {
+		if strings.HasPrefix(m.Metadata.Name, toComplete) {
+			names = append(names, m.Metadata.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Supported values for the --input-format flag. "auto" (the default) picks a
+// format from the input file's extension.
+const (
+	inputFormatAuto = "auto"
+	inputFormatCSV  = "csv"
+	inputFormatYAML = "yaml"
+	inputFormatJSON = "json"
+)
+
+// structuredRow is the canonical schema for --input-format=yaml/json: a flat
+// list of role assignments, e.g. [{project: p, email: u@x.com, role:
+// project-owner}, ...]. Field names mirror CSVRow/the CSV column headers,
+// just lowercase and snake_case as is conventional for YAML/JSON.
+type structuredRow struct {
+	Project     string `json:"project"`
+	Email       string `json:"email"`
+	Username    string `json:"username"`
+	Role        string `json:"role"`
+	SubjectType string `json:"subject_type"`
+	UserExists  string `json:"user_exists"`
+}
+
+// detectInputFormat resolves explicit (the --input-format flag value) to one
+// of inputFormatCSV/YAML/JSON, falling back to path's extension when
+// explicit is empty or inputFormatAuto.
+func detectInputFormat(path, explicit string) (string, error) {
+	switch explicit {
+	case "", inputFormatAuto:
+	case inputFormatCSV, inputFormatYAML, inputFormatJSON:
+		return explicit, nil
+	default:
+		return "", fmt.Errorf("unknown --input-format '%s' (want '%s', '%s', '%s' or '%s')", explicit, inputFormatCSV, inputFormatYAML, inputFormatJSON, inputFormatAuto)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return inputFormatCSV, nil
+	case ".yaml", ".yml":
+		return inputFormatYAML, nil
+	case ".json":
+		return inputFormatJSON, nil
+	default:
+		return "", fmt.Errorf("cannot detect input format from the extension of '%s'; pass --input-format", path)
+	}
+}
+
+// parseInputFile generalizes parseCSVFile to also accept YAML and JSON,
+// dispatching on inputFormat (see detectInputFormat). YAML/JSON rows go
+// through the same userIDMode/caseInsensitive normalization as CSV rows so
+// downstream dedup and cache lookups behave identically regardless of which
+// format a row came from.
+func parseInputFile(filename, userIDMode string, caseInsensitive bool, inputFormat string) ([]CSVRow, error) {
+	format, err := detectInputFormat(filename, inputFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == inputFormatCSV {
+		return parseCSVFile(filename, userIDMode, caseInsensitive)
+	}
+	return parseStructuredFile(filename, userIDMode, caseInsensitive)
+}
+
+// parseStructuredFile parses a YAML or JSON input file into CSVRows.
+// sigs.k8s.io/yaml.Unmarshal handles both: it converts the document to JSON
+// first (JSON is valid YAML) and decodes from there, the same approach
+// ghodss/yaml popularized for normalizing YAML config onto JSON-tagged Go
+// structs.
+func parseStructuredFile(filename, userIDMode string, caseInsensitive bool) ([]CSVRow, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open input file: %v", err)
+	}
+
+	var entries []structuredRow
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("cannot parse input file: %v", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("input file must contain at least one row")
+	}
+
+	// Always normalize emails case-insensitively for lookup/dedup purposes;
+	// usernames only when --case-insensitive is explicitly set. Mirrors
+	// parseCSVFile.
+	lowercase := caseInsensitive || userIDMode != userIDModeUsername
+
+	rows := make([]CSVRow, 0, len(entries))
+	for _, e := range entries {
+		identifier := e.Email
+		if userIDMode == userIDModeUsername {
+			identifier = e.Username
+		}
+		identifier = strings.TrimSpace(identifier)
+		if lowercase {
+			identifier = strings.ToLower(identifier)
+		}
+
+		userExists := strings.TrimSpace(e.UserExists)
+		if userExists == "" {
+			userExists = "Y"
+		}
+
+		row := CSVRow{
+			AppShortName: strings.TrimSpace(e.Project),
+			UserExists:   userExists,
+			Role:         strings.TrimSpace(e.Role),
+			SubjectType:  strings.TrimSpace(e.SubjectType),
+		}
+		if userIDMode == userIDModeUsername {
+			row.Username = identifier
+		} else {
+			row.UserEmail = identifier
+		}
+
+		// Skip empty rows, matching parseCSVFile.
+		if row.AppShortName == "" && identifier == "" {
+			continue
+		}
+
+		row.OriginalRow = len(rows) + 1
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// JournalEntry is one line of a --journal file: the outcome recorded for a
+// single CSV row right after it was processed, so an interrupted bulk run
+// can resume without reprocessing rows that already finished. CSVHash ties
+// the journal to the exact CSV contents it was written against.
+type JournalEntry struct {
+	CSVHash   string    `json:"csv_hash"`
+	RowIndex  int       `json:"row_index"`
+	Project   string    `json:"project"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role"`
+	Outcome   string    `json:"outcome"` // assigned, skipped or failed
+	Timestamp time.Time `json:"timestamp"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// journalWriter appends JournalEntry lines to a --journal file as rows are
+// processed. A nil *journalWriter is a no-op, so callers can pass one
+// through unconditionally instead of branching on whether --journal was set.
+type journalWriter struct {
+	mu   sync.Mutex
+	f    *os.File
+	hash string
+}
+
+// openJournal opens path for appending (creating it if needed) and returns a
+// journalWriter that stamps every entry with csvHash. fresh, when true,
+// truncates any existing contents first - used when an existing journal's
+// recorded hash doesn't match the CSV being processed now.
+func openJournal(path, csvHash string, fresh bool) (*journalWriter, error) {
+	flags := os.O_CREATE | os.O_APPEND | os.O_WRONLY
+	if fresh {
+		flags = os.O_CREATE | os.O_TRUNC | os.O_WRONLY
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal '%s': %w", path, err)
+	}
+	return &journalWriter{f: f, hash: csvHash}, nil
+}
+
+// append writes entry as a single JSON line, filling in CSVHash.
+func (j *journalWriter) append(entry JournalEntry) error {
+	if j == nil {
+		return nil
+	}
+	entry.CSVHash = j.hash
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.f.Write(line)
+	return err
+}
+
+// Close closes the underlying journal file.
+func (j *journalWriter) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.f.Close()
+}
+
+// hashCSVFile returns a hex-encoded sha256 hash of filename's contents, used
+// to detect whether a --journal file's entries still correspond to the CSV
+// being processed.
+func hashCSVFile(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash CSV '%s': %w", filename, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash CSV '%s': %w", filename, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// journalMatchesCSV reports whether the journal at path was written against
+// the CSV identified by csvHash, by checking the hash recorded on its first
+// line. A missing, empty or unreadable journal reports false, so the caller
+// starts a fresh one rather than trying to resume from it.
+func journalMatchesCSV(path, csvHash string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	if !scanner.Scan() {
+		return false
+	}
+	var entry JournalEntry
+	if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+		return false
+	}
+	return entry.CSVHash == csvHash
+}
+
+// ReplayJournal reads an existing --journal file and returns the outcomes
+// already recorded for rows that reached a terminal state worth skipping on
+// resume (assigned or skipped). Rows last recorded as failed are left out so
+// a resumed run retries them. A missing file is not an error - it just means
+// there is nothing to resume from.
+//
+// A truncated final line (the process was killed mid-write) stops the
+// replay at the last complete line rather than failing it outright, since
+// that's exactly the crash scenario --journal exists to recover from.
+func ReplayJournal(path string) (map[int]RowOutcome, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	outcomes := make(map[int]RowOutcome)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			break
+		}
+
+		switch entry.Outcome {
+		case "assigned", "skipped":
+			outcomes[entry.RowIndex] = RowOutcome{
+				Row:     entry.RowIndex,
+				Project: entry.Project,
+				Email:   entry.Email,
+				Role:    entry.Role,
+				Action:  entry.Outcome,
+				Reason:  entry.Error,
+			}
+		default:
+			// A later "failed" entry for a row previously marked done means
+			// it was retried and failed again after this journal was last
+			// read; don't resume past it.
+			delete(outcomes, entry.RowIndex)
+		}
+	}
+	return outcomes, nil
+}
@@ -3,29 +3,56 @@ package main
 import (
 	"context"
 	"encoding/csv"
-	"flag"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"os/signal"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/nobl9/nobl9-go/manifest"
 	v1alphaRoleBinding "github.com/nobl9/nobl9-go/manifest/v1alpha/rolebinding"
 	"github.com/nobl9/nobl9-go/sdk"
+	"github.com/spf13/cobra"
 )
 
-// ProcessingStats tracks the results of bulk processing
+// ProcessingStats tracks the results of bulk processing. It doubles as the
+// payload for --output=json, with Results (see RowOutcome) carrying the
+// per-row detail that --output=jsonl streams one object at a time.
 type ProcessingStats struct {
-	TotalRows            int
-	Processed            int
-	Assigned             int
-	SkippedAlreadyOwner  int
-	SkippedUserNotExists int
-	SkippedInvalidData   int
-	Failed               int
-	Errors               []string
+	TotalRows            int          `json:"total_rows"`
+	Processed            int          `json:"processed"`
+	Assigned             int          `json:"assigned"`
+	SkippedAlreadyOwner  int          `json:"skipped_already_owner"`
+	SkippedUserNotExists int          `json:"skipped_user_not_exists"`
+	SkippedInvalidData   int          `json:"skipped_invalid_data"`
+	DuplicateRows        int          `json:"duplicate_rows"`
+	ConflictingRows      int          `json:"conflicting_rows"`
+	Failed               int          `json:"failed"`
+	Removed              int          `json:"removed"`
+	SkippedRemoval       int          `json:"skipped_removal"`
+	ResumedFromJournal   int          `json:"resumed_from_journal,omitempty"`
+	PlanNoop             int          `json:"plan_no_op,omitempty"`
+	PlanCreate           int          `json:"plan_create,omitempty"`
+	PlanReplace          int          `json:"plan_replace,omitempty"`
+	Errors               []string     `json:"errors,omitempty"`
+	Results              []RowOutcome `json:"results,omitempty"`
+	Plan                 []PlanEntry  `json:"plan,omitempty"`
+}
+
+// RowOutcome is the per-row result recorded during bulk processing, emitted
+// to stdout by --output=json (inside ProcessingStats.Results) or
+// --output=jsonl (one object per line).
+type RowOutcome struct {
+	Row         int    `json:"row"`
+	Project     string `json:"project"`
+	Email       string `json:"email,omitempty"`
+	Role        string `json:"role"`
+	Action      string `json:"action"` // assigned, skipped or failed; on --dry-run, a dispatched row's plan action (no-op, create or replace)
+	Reason      string `json:"reason,omitempty"`
+	BindingName string `json:"binding_name,omitempty"`
 }
 
 // CSVRow represents a row from the CSV file
@@ -34,7 +61,73 @@ type CSVRow struct {
 	ProductManager string
 	UserExists     string
 	UserEmail      string
+	Username       string
+	Role           string
 	SLOs           string
+	SubjectType    string // "user" (default), "group" or "service-account"; see subjectType* constants
+	// OriginalRow is the row's 1-indexed position in the parsed input file
+	// (parseCSVFile/parseStructuredFile number rows in the order they're
+	// kept, skipping only blank rows), set once at parse time and carried
+	// unchanged through dedupeRows and pre-validation. Every row number
+	// reported to the user - in stats.Results, stats.Plan, the journal and
+	// logging - is this field, not a loop index, since those slices are
+	// reordered and shrunk (by dedupeRows, then by pre-validation) before a
+	// row reaches the worker pool.
+	OriginalRow int
+}
+
+// ResolvedSubjectType returns the row's subject type, defaulting to
+// subjectTypeUser when the column was absent or left blank.
+func (r CSVRow) ResolvedSubjectType() string {
+	t := strings.ToLower(strings.TrimSpace(r.SubjectType))
+	if t == "" {
+		return subjectTypeUser
+	}
+	return t
+}
+
+// Supported values for the --user-id flag, controlling whether rows are
+// keyed by email address or by Nobl9 username.
+const (
+	userIDModeEmail    = "email"
+	userIDModeUsername = "username"
+)
+
+// Supported values for the --backend flag. backendAPI is the only one
+// implemented: see Backend's doc comment in backend.go for why there's no
+// sloctl-backed alternative to select. The flag still exists (rather than
+// being silently dropped) so scripts built against a --backend surface fail
+// with a clear error instead of an "unknown flag".
+const (
+	backendAPI = "api"
+)
+
+// Supported values for the --output flag. Human-readable log lines always go
+// to stderr (see logger in logging.go); these control what, if anything, is
+// written to stdout for machine consumption.
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputJSONL = "jsonl"
+)
+
+// Supported values for the --subject-type flag and the CSV's 'Subject Type'
+// column, controlling which field of v1alphaRoleBinding.Spec a row binds to.
+// subjectTypeServiceAccount is accepted but not yet implemented.
+const (
+	subjectTypeUser           = "user"
+	subjectTypeGroup          = "group"
+	subjectTypeServiceAccount = "service-account"
+)
+
+// Identifier returns the value that should be used to look up the subject for
+// this row: for a user row, an email or username depending on userIDMode; for
+// a group row, the same column holds the group's name directly.
+func (r CSVRow) Identifier(userIDMode string) string {
+	if userIDMode == userIDModeUsername {
+		return r.Username
+	}
+	return r.UserEmail
 }
 
 // Valid roles that can be assigned
@@ -78,81 +171,219 @@ func getValidRoles() string {
 	return strings.Join(roles, ", ")
 }
 
-// checkExistingRoleBinding checks if user already has the specified role for the project
-func checkExistingRoleBinding(ctx context.Context, client *sdk.Client, projectName, userID, role string) (bool, error) {
-	// Get existing role bindings for the project
-	// This is a simplified check - in a production environment, you'd want more robust checking
+// resolveUserID looks up the user identified by userID (an email address)
+// and returns their Nobl9 user ID. userIDMode is validated at flag-parsing
+// time in runRoot; userIDModeUsername never reaches here, since the Nobl9 SDK
+// exposes no lookup-by-username endpoint to resolve it against (see
+// userIDModeUsername's rejection in runRoot).
+func resolveUserID(ctx context.Context, client *sdk.Client, userID, userIDMode string) (string, error) {
+	if userIDMode == userIDModeUsername {
+		return "", fmt.Errorf("--user-id=username is not supported: the Nobl9 SDK has no lookup-by-username endpoint")
+	}
 
-	// Note: The Nobl9 SDK doesn't appear to have a direct method to check existing role bindings
-	// In practice, you might need to implement this differently based on your specific requirements
-	// For now, we'll return false to allow the assignment to proceed
+	var resolvedUserID string
+	var notFound bool
+	err := retryErr(ctx, defaultRetryConfig, "lookup user", func() error {
+		user, err := client.Users().V2().GetUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			notFound = true
+			return nil
+		}
+		resolvedUserID = user.UserID
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error retrieving user from Nobl9 API: %v", err)
+	}
+	if notFound {
+		return "", fmt.Errorf("%w: '%s'", ErrUserNotFound, userID)
+	}
+	return resolvedUserID, nil
+}
 
-	log.Printf("Checking existing role bindings for user %s in project %s with role %s", userID, projectName, role)
+// organizationRoleNames are org-scoped roles that don't follow the
+// "organization-" naming convention, so isOrganizationRole's prefix check
+// alone would miss them.
+var organizationRoleNames = map[string]bool{
+	"viewer-status-page-manager": true,
+}
 
-	// TODO: Implement actual role binding check if SDK supports it
-	// This would involve querying existing role bindings and checking if this specific
-	// user-project-role combination already exists
+// isOrganizationRole reports whether role is an organization-scoped role
+// (as opposed to a project-scoped one). Organization roles can be bound to
+// either a user or a group (see assignRoleBinding) and, unlike project
+// roles, don't require a project: runRoot and processBulkAssignment both
+// consult it to skip their usual "project is required" validation.
+func isOrganizationRole(role string) bool {
+	return strings.HasPrefix(role, "organization-") || organizationRoleNames[role]
+}
 
-	return false, nil
+// applyRoleBinding creates a role binding named after sanitizedProject and
+// sanitizedSubject, with spec built by buildSpec. It returns the binding's
+// name, and records it in cache when non-nil.
+func applyRoleBinding(ctx context.Context, backend Backend, cache *roleBindingCache, key roleBindingKey, sanitizedProject, sanitizedSubject string, buildSpec func(name string) v1alphaRoleBinding.Spec) (string, error) {
+	// Use nanosecond precision to avoid race conditions
+	roleBindingName := fmt.Sprintf("assign-%s-%s-%d", sanitizedProject, sanitizedSubject, time.Now().UnixNano())
+
+	roleBinding := v1alphaRoleBinding.New(
+		v1alphaRoleBinding.Metadata{Name: roleBindingName},
+		buildSpec(roleBindingName),
+	)
+
+	if err := backend.ApplyRoleBinding(ctx, roleBinding); err != nil {
+		return "", fmt.Errorf("failed to apply role binding: %v", err)
+	}
+
+	if cache != nil {
+		cache.record(key.project, key.subject, key.subjectType, key.role, roleBindingName)
+	}
+	return roleBindingName, nil
 }
 
-// assignProjectOwnerRole assigns the specified role to a user for a project
-func assignProjectOwnerRole(ctx context.Context, client *sdk.Client, projectName, userEmail, role string, dryRun bool) error {
-	// Step 1: Check if the user exists by their email
-	user, err := client.Users().V2().GetUser(ctx, userEmail)
-	if err != nil {
-		return fmt.Errorf("error retrieving user from Nobl9 API: %v", err)
+// assignRoleBinding assigns role to the subject identified by subjectID for
+// projectName, dispatching on subjectType (see the subjectType* constants).
+// It returns the name of the role binding that now satisfies the row (empty
+// for a dry run, since nothing is actually created) and, if the subject
+// already held a binding for a different role (a BuildPlan "replace"), the
+// role that binding is superseding (empty otherwise).
+// userIDMode only applies to subjectTypeUser rows; it's ignored otherwise.
+// cache is consulted to skip rows that are already satisfied, and is updated
+// on success so later calls in the same run see the fresh state. When desired
+// is non-nil (i.e. --reconcile is in effect), every row that resolves to a
+// real subject is recorded there, regardless of outcome, so reconcile knows
+// this binding is wanted and must not be removed.
+func assignRoleBinding(ctx context.Context, backend Backend, projectName, subjectID, role string, dryRun bool, userIDMode, subjectType string, cache *roleBindingCache, desired *desiredSet) (bindingName, priorRole string, err error) {
+	switch subjectType {
+	case subjectTypeGroup:
+		return assignGroupRole(ctx, backend, projectName, subjectID, role, dryRun, cache, desired)
+	case subjectTypeServiceAccount:
+		return "", "", fmt.Errorf("--subject-type=service-account is not yet supported")
+	case subjectTypeUser, "":
+		return assignUserRole(ctx, backend, projectName, subjectID, role, dryRun, userIDMode, cache, desired)
+	default:
+		return "", "", fmt.Errorf("unknown subject type '%s'", subjectType)
 	}
+}
 
-	if user == nil {
-		return fmt.Errorf("user with email '%s' not found", userEmail)
+// supersedeExistingBinding looks up any binding cache has for (project,
+// subject, subjectType) under a role other than role, and - unless dryRun -
+// deletes it: Nobl9 allows only one binding per (project, subject), and
+// applyRoleBinding always creates a new, uniquely-named binding rather than
+// updating one in place, so the old one must be removed explicitly for a
+// role change to actually take effect. Returns the role it superseded
+// (empty if there was nothing to supersede), which callers thread through to
+// RowOutcome/auditRecord as the "replace" outcome's prior role.
+func supersedeExistingBinding(ctx context.Context, backend Backend, cache *roleBindingCache, project, subject, subjectType, role string, dryRun bool) (priorRole string, err error) {
+	if cache == nil {
+		return "", nil
+	}
+	previousRole, previousName, ok := cache.findBySubject(project, subject, subjectType)
+	if !ok || previousRole == role {
+		return "", nil
+	}
+	if dryRun {
+		return previousRole, nil
+	}
+	if err := backend.DeleteRoleBinding(ctx, previousName); err != nil {
+		return "", fmt.Errorf("failed to remove prior role binding: %v", err)
+	}
+	cache.remove(roleBindingKey{project: project, subject: subject, subjectType: subjectType, role: previousRole})
+	logger.Info("removed prior role binding", "role", previousRole, "subject_type", subjectType, "subject", subject, "project", project)
+	return previousRole, nil
+}
+
+// assignUserRole assigns role to the user identified by userID (an email
+// address or a Nobl9 username, depending on userIDMode) for projectName.
+// See assignRoleBinding for the meaning of cache and desired.
+func assignUserRole(ctx context.Context, backend Backend, projectName, userID, role string, dryRun bool, userIDMode string, cache *roleBindingCache, desired *desiredSet) (bindingName, priorRole string, err error) {
+	// Step 1: Check if the user exists, looking them up by email or username
+	resolvedUserID, err := backend.LookupUser(ctx, userID, userIDMode)
+	if err != nil {
+		return "", "", err
 	}
 
-	log.Printf("Found user: %s (ID: %s)", userEmail, user.UserID)
+	logger.Info("found user", "identifier", userID, "user_id", resolvedUserID)
+
+	key := roleBindingKey{project: projectName, subject: resolvedUserID, subjectType: subjectTypeUser, role: role}
+	desired.add(key)
 
 	// Step 2: Check if user already has this role for this project
-	exists, err := checkExistingRoleBinding(ctx, client, projectName, user.UserID, role)
-	if err != nil {
-		log.Printf("Warning: Could not check existing role bindings: %v", err)
-		// Continue with assignment even if we can't check
+	if cache != nil && cache.has(projectName, resolvedUserID, subjectTypeUser, role) {
+		return "", "", fmt.Errorf("%w: user already has role '%s' for project '%s'", ErrAlreadyAssigned, role, projectName)
 	}
 
-	if exists {
-		return fmt.Errorf("user already has role '%s' for project '%s'", role, projectName)
+	priorRole, err = supersedeExistingBinding(ctx, backend, cache, projectName, resolvedUserID, subjectTypeUser, role, dryRun)
+	if err != nil {
+		return "", "", err
 	}
 
 	if dryRun {
-		log.Printf("DRY RUN: Would assign role '%s' to user '%s' in project '%s'", role, userEmail, projectName)
-		return nil
+		logger.Info("dry run: would assign role to user", "role", role, "user", userID, "project", projectName)
+		return "", priorRole, nil
 	}
 
-	// Step 3: Generate a unique name for the role binding
-	sanitizedProject := sanitizeName(projectName)
-	sanitizedEmail := sanitizeName(userEmail)
-	// Use nanosecond precision to avoid race conditions
-	roleBindingName := fmt.Sprintf("assign-%s-%s-%d", sanitizedProject, sanitizedEmail, time.Now().UnixNano())
-
-	// Step 4: Create the role binding object
-	roleBinding := v1alphaRoleBinding.New(
-		v1alphaRoleBinding.Metadata{Name: roleBindingName},
-		v1alphaRoleBinding.Spec{
-			User:       ptr(user.UserID),
+	roleBindingName, err := applyRoleBinding(ctx, backend, cache, key, sanitizeName(projectName), sanitizeName(userID), func(string) v1alphaRoleBinding.Spec {
+		return v1alphaRoleBinding.Spec{
+			User:       ptr(resolvedUserID),
 			RoleRef:    role,
 			ProjectRef: projectName,
-		},
-	)
+		}
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	logger.Info("assigned role to user", "role", role, "user", userID, "project", projectName)
+	return roleBindingName, priorRole, nil
+}
 
-	// Step 5: Apply the role binding to assign the role
-	if err := client.Objects().V1().Apply(ctx, []manifest.Object{roleBinding}); err != nil {
-		return fmt.Errorf("failed to apply role binding: %v", err)
+// assignGroupRole assigns role to the Nobl9 group named groupName for
+// projectName. Groups are referenced directly by name, so there's no lookup
+// equivalent to resolveUserID. See assignRoleBinding for the meaning of cache
+// and desired.
+func assignGroupRole(ctx context.Context, backend Backend, projectName, groupName, role string, dryRun bool, cache *roleBindingCache, desired *desiredSet) (bindingName, priorRole string, err error) {
+	if groupName == "" {
+		return "", "", fmt.Errorf("empty group name")
 	}
 
-	log.Printf("Successfully assigned role '%s' to user '%s' in project '%s'", role, userEmail, projectName)
-	return nil
+	key := roleBindingKey{project: projectName, subject: groupName, subjectType: subjectTypeGroup, role: role}
+	desired.add(key)
+
+	if cache != nil && cache.has(projectName, groupName, subjectTypeGroup, role) {
+		return "", "", fmt.Errorf("%w: group already has role '%s' for project '%s'", ErrAlreadyAssigned, role, projectName)
+	}
+
+	priorRole, err = supersedeExistingBinding(ctx, backend, cache, projectName, groupName, subjectTypeGroup, role, dryRun)
+	if err != nil {
+		return "", "", err
+	}
+
+	if dryRun {
+		logger.Info("dry run: would assign role to group", "role", role, "group", groupName, "project", projectName)
+		return "", priorRole, nil
+	}
+
+	roleBindingName, err := applyRoleBinding(ctx, backend, cache, key, sanitizeName(projectName), sanitizeName(groupName), func(string) v1alphaRoleBinding.Spec {
+		return v1alphaRoleBinding.Spec{
+			GroupRef:   ptr(groupName),
+			RoleRef:    role,
+			ProjectRef: projectName,
+		}
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	logger.Info("assigned role to group", "role", role, "group", groupName, "project", projectName)
+	return roleBindingName, priorRole, nil
 }
 
-// parseCSVFile parses the CSV file and returns the data rows
-func parseCSVFile(filename string) ([]CSVRow, error) {
+// parseCSVFile parses the CSV file and returns the data rows. userIDMode selects
+// whether rows are keyed by the 'User Email' or 'Username' column, and
+// caseInsensitive lowercases the identifier (always applied for email mode).
+func parseCSVFile(filename, userIDMode string, caseInsensitive bool) ([]CSVRow, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open CSV file: %v", err)
@@ -171,7 +402,7 @@ func parseCSVFile(filename string) ([]CSVRow, error) {
 
 	// Find column indices
 	header := records[0]
-	var appNameIdx, userEmailIdx, userExistsIdx = -1, -1, -1
+	var appNameIdx, userEmailIdx, usernameIdx, userExistsIdx, roleIdx, subjectTypeIdx = -1, -1, -1, -1, -1, -1
 
 	for i, col := range header {
 		colLower := strings.ToLower(strings.TrimSpace(col))
@@ -180,20 +411,37 @@ func parseCSVFile(filename string) ([]CSVRow, error) {
 			appNameIdx = i
 		case "user email":
 			userEmailIdx = i
+		case "username":
+			usernameIdx = i
 		case "user exists":
 			userExistsIdx = i
+		case "role":
+			roleIdx = i
+		case "subject type":
+			subjectTypeIdx = i
 		}
 	}
 
-	if appNameIdx == -1 || userEmailIdx == -1 {
-		return nil, fmt.Errorf("CSV file must contain 'App Short Name' and 'User Email' columns")
+	identifierIdx := userEmailIdx
+	identifierCol := "User Email"
+	if userIDMode == userIDModeUsername {
+		identifierIdx = usernameIdx
+		identifierCol = "Username"
 	}
 
+	if appNameIdx == -1 || identifierIdx == -1 {
+		return nil, fmt.Errorf("CSV file must contain 'App Short Name' and '%s' columns", identifierCol)
+	}
+
+	// Always normalize emails case-insensitively for lookup/dedup purposes;
+	// usernames only when --case-insensitive is explicitly set.
+	lowercase := caseInsensitive || userIDMode != userIDModeUsername
+
 	// Parse data rows
 	var rows []CSVRow
 	for i, record := range records[1:] {
-		if len(record) <= appNameIdx || len(record) <= userEmailIdx {
-			log.Printf("Warning: Row %d has insufficient columns, skipping", i+2)
+		if len(record) <= appNameIdx || len(record) <= identifierIdx {
+			logger.Warn("row has insufficient columns, skipping", "row", i+2)
 			continue
 		}
 
@@ -202,100 +450,390 @@ func parseCSVFile(filename string) ([]CSVRow, error) {
 			userExists = strings.TrimSpace(record[userExistsIdx])
 		}
 
+		rowRole := ""
+		if roleIdx != -1 && len(record) > roleIdx {
+			rowRole = strings.TrimSpace(record[roleIdx])
+		}
+
+		subjectType := ""
+		if subjectTypeIdx != -1 && len(record) > subjectTypeIdx {
+			subjectType = strings.TrimSpace(record[subjectTypeIdx])
+		}
+
+		identifier := strings.TrimSpace(record[identifierIdx])
+		if lowercase {
+			identifier = strings.ToLower(identifier)
+		}
+
 		row := CSVRow{
 			AppShortName: strings.TrimSpace(record[appNameIdx]),
-			UserEmail:    strings.TrimSpace(record[userEmailIdx]),
 			UserExists:   userExists,
+			Role:         rowRole,
+			SubjectType:  subjectType,
+		}
+		if userIDMode == userIDModeUsername {
+			row.Username = identifier
+		} else {
+			row.UserEmail = identifier
 		}
 
 		// Skip empty rows
-		if row.AppShortName == "" && row.UserEmail == "" {
+		if row.AppShortName == "" && identifier == "" {
 			continue
 		}
 
+		row.OriginalRow = len(rows) + 1
 		rows = append(rows, row)
 	}
 
 	return rows, nil
 }
 
-// processBulkAssignment processes the CSV file for bulk role assignments
-func processBulkAssignment(ctx context.Context, client *sdk.Client, filename, role string, dryRun bool) (*ProcessingStats, error) {
+// rowKey identifies a row by the project/subject pair it targets, used to
+// detect duplicate and conflicting rows before any assignments are attempted.
+type rowKey struct {
+	project     string
+	identifier  string
+	subjectType string
+}
+
+// dedupeRows groups rows by (project, user) and removes exact duplicates (same
+// project, user and effective role). Rows that share a project/user pair but
+// specify different roles via the per-row Role column are reported as
+// conflicts and the whole group is skipped, since applying any one of them
+// would silently override the others.
+func dedupeRows(rows []CSVRow, defaultRole, userIDMode string) (kept []CSVRow, duplicateRows int, conflicts []string) {
+	type group struct {
+		rows  []CSVRow
+		roles map[string]bool
+	}
+	order := make([]rowKey, 0, len(rows))
+	groups := make(map[rowKey]*group, len(rows))
+
+	for _, row := range rows {
+		effectiveRole := row.Role
+		if effectiveRole == "" {
+			effectiveRole = defaultRole
+		}
+		k := rowKey{project: row.AppShortName, identifier: row.Identifier(userIDMode), subjectType: row.ResolvedSubjectType()}
+
+		g, ok := groups[k]
+		if !ok {
+			g = &group{roles: make(map[string]bool)}
+			groups[k] = g
+			order = append(order, k)
+		}
+		g.rows = append(g.rows, row)
+		g.roles[effectiveRole] = true
+	}
+
+	for _, k := range order {
+		g := groups[k]
+		switch {
+		case len(g.roles) > 1:
+			roles := make([]string, 0, len(g.roles))
+			for r := range g.roles {
+				roles = append(roles, r)
+			}
+			conflicts = append(conflicts, fmt.Sprintf("project '%s' user '%s' has conflicting roles: %s", k.project, k.identifier, strings.Join(roles, ", ")))
+		case len(g.rows) > 1:
+			duplicateRows += len(g.rows) - 1
+			logger.Warn("collapsing duplicate rows", "count", len(g.rows)-1, "project", k.project, "user", k.identifier)
+			kept = append(kept, g.rows[0])
+		default:
+			kept = append(kept, g.rows[0])
+		}
+	}
+	return kept, duplicateRows, conflicts
+}
+
+// reconcileOptions configures --reconcile: treating the CSV as the desired
+// state for role and removing any existing binding of that role which is not
+// represented in the CSV.
+type reconcileOptions struct {
+	enabled bool
+	scope   string // one of reconcileScopeProject, reconcileScopeProjectsInCSV, reconcileScopeAll
+	confirm bool
+}
+
+// auditContext carries the --audit-log destination and the fields common to
+// every record written to it during a run: the actor performing the
+// operation (NOBL9_CLIENT_ID) and a correlationID shared by every record from
+// this invocation. A zero-value auditContext (writer nil) makes recording a
+// no-op, matching auditWriter's own nil-safety.
+type auditContext struct {
+	actor         string
+	correlationID string
+	writer        *auditWriter
+	redactEmails  bool
+}
+
+// record writes one audit entry, logging (rather than failing the run) if
+// the write itself fails - an audit log problem shouldn't abort role
+// assignment.
+func (a auditContext) record(action, subjectEmail, project, role, priorRole, outcome, errMsg string) {
+	err := a.writer.record(auditRecord{
+		Actor:         a.actor,
+		Action:        action,
+		SubjectEmail:  redactEmail(subjectEmail, a.redactEmails),
+		Project:       project,
+		Role:          role,
+		PriorRole:     priorRole,
+		Outcome:       outcome,
+		Error:         errMsg,
+		CorrelationID: a.correlationID,
+		Timestamp:     time.Now(),
+	})
+	if err != nil {
+		logger.Warn("failed to write audit log entry", "error", err)
+	}
+}
+
+// processBulkAssignment processes the CSV file for bulk role assignments.
+// userIDMode and caseInsensitive control how rows are identified; see parseCSVFile.
+func processBulkAssignment(ctx context.Context, backend Backend, filename, role string, dryRun bool, userIDMode string, caseInsensitive bool, refreshBindingsEvery int, reconcile reconcileOptions, concurrency concurrencyOptions, journalPath, inputFormat string, audit auditContext) (*ProcessingStats, error) {
 	stats := &ProcessingStats{}
 
-	// Parse CSV file
-	rows, err := parseCSVFile(filename)
+	// Parse the input file (CSV, YAML or JSON - see --input-format).
+	rows, err := parseInputFile(filename, userIDMode, caseInsensitive, inputFormat)
 	if err != nil {
 		return stats, err
 	}
 
+	// --journal: resume from a previous, interrupted run against this same
+	// CSV. A hash mismatch (different CSV, or no journal yet) starts fresh
+	// rather than trying to resume against stale entries.
+	var resumed map[int]RowOutcome
+	var journal *journalWriter
+	if journalPath != "" && !dryRun {
+		csvHash, err := hashCSVFile(filename)
+		if err != nil {
+			return stats, err
+		}
+		if journalMatchesCSV(journalPath, csvHash) {
+			resumed, err = ReplayJournal(journalPath)
+			if err != nil {
+				return stats, err
+			}
+			logger.Info("resuming from journal", "journal", journalPath, "resumed_rows", len(resumed))
+		} else {
+			logger.Info("journal does not match this input (or doesn't exist yet); starting fresh", "journal", journalPath)
+		}
+		journal, err = openJournal(journalPath, csvHash, resumed == nil)
+		if err != nil {
+			return stats, err
+		}
+		defer journal.Close()
+	}
+
+	rows, duplicateRows, conflicts := dedupeRows(rows, role, userIDMode)
+	stats.DuplicateRows = duplicateRows
+	stats.ConflictingRows = len(conflicts)
+	for _, c := range conflicts {
+		logger.Error("skipping all rows for this project/user pair", "reason", c)
+		stats.Errors = append(stats.Errors, c)
+	}
+
 	stats.TotalRows = len(rows)
-	log.Printf("Processing %d rows from CSV file...", stats.TotalRows)
+	logger.Info("processing rows", "total_rows", stats.TotalRows)
 
 	if dryRun {
-		log.Printf("DRY RUN MODE: No actual changes will be made")
+		logger.Info("dry run mode: no actual changes will be made")
+	}
+
+	cache, err := newRoleBindingCache(ctx, backend)
+	if err != nil {
+		logger.Warn("could not load existing role bindings, proceeding without a cache", "error", err)
+		cache = nil
+	}
+
+	var desired *desiredSet
+	if reconcile.enabled {
+		desired = newDesiredSet()
 	}
 
-	// Process each row
-	for i, row := range rows {
+	// Validate rows up front (cheap, no network access); only rows that pass
+	// are handed to the worker pool. Every row number reported below comes
+	// from row.OriginalRow (see CSVRow), not a loop index, since dedupeRows
+	// has already reordered and shrunk rows relative to the input file.
+	dispatchRows := make([]CSVRow, 0, len(rows))
+	// journalSkip records a pre-validation skip so a future resume doesn't
+	// re-validate this row; a nil journal makes it a no-op.
+	journalSkip := func(row CSVRow, userID, reason string) {
+		err := journal.append(JournalEntry{RowIndex: row.OriginalRow, Project: row.AppShortName, Email: userID, Role: role, Outcome: "skipped", Error: reason, Timestamp: time.Now()})
+		if err != nil {
+			logger.Warn("failed to write journal entry", "row", row.OriginalRow, "error", err)
+		}
+	}
+	for _, row := range rows {
 		stats.Processed++
 
-		log.Printf("Processing row %d: Project '%s', User '%s'", i+1, row.AppShortName, row.UserEmail)
+		userID := row.Identifier(userIDMode)
+
+		if outcome, ok := resumed[row.OriginalRow]; ok {
+			logger.Info("row already finished per journal, skipping", "row", row.OriginalRow, "outcome", outcome.Action, "journal", journalPath)
+			stats.ResumedFromJournal++
+			stats.Results = append(stats.Results, outcome)
+			if outcome.Action == "assigned" {
+				desired.add(roleBindingKey{project: row.AppShortName, subject: userID, subjectType: row.ResolvedSubjectType(), role: outcome.Role})
+			}
+			continue
+		}
+
+		effectiveRole := row.Role
+		if effectiveRole == "" {
+			effectiveRole = role
+		}
 
-		// Validate row data
-		if row.AppShortName == "" {
-			err := fmt.Sprintf("Row %d: Empty project name", i+1)
-			log.Printf("Skipping - %s", err)
+		// Organization roles (see isOrganizationRole) apply at the org level,
+		// to a user or a group, and don't need a project.
+		if row.AppShortName == "" && !isOrganizationRole(effectiveRole) {
+			reason := "empty project name"
+			logger.Info("skipping row", "row", row.OriginalRow, "reason", reason)
 			stats.SkippedInvalidData++
-			stats.Errors = append(stats.Errors, err)
+			stats.Errors = append(stats.Errors, fmt.Sprintf("Row %d: %s", row.OriginalRow, reason))
+			stats.Results = append(stats.Results, RowOutcome{Row: row.OriginalRow, Project: row.AppShortName, Email: userID, Role: role, Action: "skipped", Reason: reason})
+			journalSkip(row, userID, reason)
 			continue
 		}
 
-		if row.UserEmail == "" {
-			err := fmt.Sprintf("Row %d: Empty user email", i+1)
-			log.Printf("Skipping - %s", err)
+		if userID == "" {
+			reason := "empty user identifier"
+			logger.Info("skipping row", "row", row.OriginalRow, "reason", reason)
 			stats.SkippedInvalidData++
-			stats.Errors = append(stats.Errors, err)
+			stats.Errors = append(stats.Errors, fmt.Sprintf("Row %d: %s", row.OriginalRow, reason))
+			stats.Results = append(stats.Results, RowOutcome{Row: row.OriginalRow, Project: row.AppShortName, Email: userID, Role: role, Action: "skipped", Reason: reason})
+			journalSkip(row, userID, reason)
 			continue
 		}
 
-		if !validateEmail(row.UserEmail) {
-			err := fmt.Sprintf("Row %d: Invalid email format '%s'", i+1, row.UserEmail)
-			log.Printf("Skipping - %s", err)
+		if row.ResolvedSubjectType() == subjectTypeUser && userIDMode != userIDModeUsername && !validateEmail(userID) {
+			reason := fmt.Sprintf("invalid email format '%s'", userID)
+			logger.Info("skipping row", "row", row.OriginalRow, "reason", reason)
 			stats.SkippedInvalidData++
-			stats.Errors = append(stats.Errors, err)
+			stats.Errors = append(stats.Errors, fmt.Sprintf("Row %d: %s", row.OriginalRow, reason))
+			stats.Results = append(stats.Results, RowOutcome{Row: row.OriginalRow, Project: row.AppShortName, Email: userID, Role: role, Action: "skipped", Reason: reason})
+			journalSkip(row, userID, reason)
 			continue
 		}
 
 		// Skip if user doesn't exist in Nobl9 (according to CSV)
 		if strings.ToUpper(row.UserExists) == "N" {
-			log.Printf("Skipping %s - user marked as not existing in Nobl9", row.UserEmail)
+			reason := "user marked as not existing in Nobl9"
+			logger.Info("skipping row", "user", userID, "reason", reason)
 			stats.SkippedUserNotExists++
+			stats.Results = append(stats.Results, RowOutcome{Row: row.OriginalRow, Project: row.AppShortName, Email: userID, Role: role, Action: "skipped", Reason: reason})
+			journalSkip(row, userID, reason)
 			continue
 		}
 
-		// Attempt to assign role
-		err := assignProjectOwnerRole(ctx, client, row.AppShortName, row.UserEmail, role, dryRun)
-		if err != nil {
-			errorMsg := fmt.Sprintf("Row %d: %v", i+1, err)
+		dispatchRows = append(dispatchRows, row)
+	}
 
-			// Check if it's an "already has role" error
-			if strings.Contains(err.Error(), "already has role") {
-				log.Printf("User '%s' already has role '%s' for project '%s' - skipping", row.UserEmail, role, row.AppShortName)
+	var plan []PlanEntry
+	if dryRun {
+		plan = BuildPlan(dispatchRows, role, userIDMode, cache)
+		stats.Plan = plan
+		for _, entry := range plan {
+			switch entry.Action {
+			case planActionNoop:
+				stats.PlanNoop++
+			case planActionCreate:
+				stats.PlanCreate++
+			case planActionReplace:
+				stats.PlanReplace++
+			}
+		}
+	}
+
+	results := runWorkerPool(ctx, backend, dispatchRows, role, dryRun, userIDMode, cache, desired, concurrency, refreshBindingsEvery, journal)
+
+	for n, res := range results {
+		row := dispatchRows[n]
+		userID := row.Identifier(userIDMode)
+		outcome := RowOutcome{Row: row.OriginalRow, Project: row.AppShortName, Email: userID, Role: res.role}
+
+		if dryRun {
+			entry := plan[n]
+			outcome.Role = entry.Role
+			outcome.Action = entry.Action
+			if entry.Action == planActionReplace {
+				outcome.Reason = fmt.Sprintf("would replace existing role '%s'", entry.PreviousRole)
+			}
+			stats.Results = append(stats.Results, outcome)
+			continue
+		}
+
+		if res.err != nil {
+			errorMsg := fmt.Sprintf("Row %d: %v", row.OriginalRow, res.err)
+
+			if errors.Is(res.err, ErrAlreadyAssigned) {
+				logger.Info("user already has role, skipping", "user", userID, "role", res.role, "project", row.AppShortName)
 				stats.SkippedAlreadyOwner++
+				outcome.Action = "skipped"
+				outcome.Reason = "already assigned"
 			} else {
-				log.Printf("Failed to assign role: %v", err)
+				logger.Error("failed to assign role", "error", res.err)
 				stats.Failed++
 				stats.Errors = append(stats.Errors, errorMsg)
+				outcome.Action = "failed"
+				outcome.Reason = res.err.Error()
 			}
 		} else {
 			stats.Assigned++
+			outcome.Action = "assigned"
+			outcome.BindingName = res.bindingName
+			if res.priorRole != "" {
+				outcome.Reason = fmt.Sprintf("replaced prior role '%s'", res.priorRole)
+			}
+		}
+
+		audit.record(auditActionAssignRole, userID, row.AppShortName, outcome.Role, res.priorRole, outcome.Action, outcome.Reason)
+		stats.Results = append(stats.Results, outcome)
+	}
 
-			// Add small delay to avoid overwhelming the API
-			if !dryRun {
-				time.Sleep(500 * time.Millisecond)
+	// Pre-validation skips and dispatched results were appended in two
+	// separate passes, so restore row order for --output=json/jsonl.
+	sort.Slice(stats.Results, func(a, b int) bool { return stats.Results[a].Row < stats.Results[b].Row })
+
+	if reconcile.enabled {
+		if cache == nil {
+			return stats, fmt.Errorf("cannot reconcile: existing role bindings could not be loaded")
+		}
+
+		var scopeProjects map[string]bool
+		switch reconcile.scope {
+		case reconcileScopeAll:
+			scopeProjects = nil
+		case reconcileScopeProjectsInCSV:
+			scopeProjects = make(map[string]bool, len(rows))
+			for _, row := range rows {
+				scopeProjects[row.AppShortName] = true
+			}
+		case reconcileScopeProject:
+			// Unlike projects-in-csv, --scope=project asserts the input is
+			// about a single project, and refuses to guess if it isn't -
+			// multi-project inputs should say so explicitly via
+			// --scope=projects-in-csv instead.
+			scopeProjects = make(map[string]bool, 1)
+			for _, row := range rows {
+				scopeProjects[row.AppShortName] = true
 			}
+			if len(scopeProjects) > 1 {
+				projects := make([]string, 0, len(scopeProjects))
+				for p := range scopeProjects {
+					projects = append(projects, p)
+				}
+				sort.Strings(projects)
+				return stats, fmt.Errorf("--scope=project requires the input to reference exactly one project, found %d (%s); use --scope=projects-in-csv for multi-project inputs", len(projects), strings.Join(projects, ", "))
+			}
+		default:
+			return stats, fmt.Errorf("unknown --scope '%s'", reconcile.scope)
 		}
+
+		removed, skippedRemoval := reconcileRoleBindings(ctx, backend, cache, role, desired, scopeProjects, dryRun, reconcile.confirm, audit)
+		stats.Removed = removed
+		stats.SkippedRemoval = skippedRemoval
 	}
 
 	return stats, nil
@@ -311,7 +849,31 @@ func printStats(stats *ProcessingStats) {
 	fmt.Printf("Skipped (already owner): %d\n", stats.SkippedAlreadyOwner)
 	fmt.Printf("Skipped (user not exists): %d\n", stats.SkippedUserNotExists)
 	fmt.Printf("Skipped (invalid data): %d\n", stats.SkippedInvalidData)
+	fmt.Printf("Duplicate rows collapsed: %d\n", stats.DuplicateRows)
+	fmt.Printf("Conflicting rows skipped: %d\n", stats.ConflictingRows)
 	fmt.Printf("Failed: %d\n", stats.Failed)
+	fmt.Printf("Removed (reconcile): %d\n", stats.Removed)
+	fmt.Printf("Skipped removal (reconcile): %d\n", stats.SkippedRemoval)
+	if stats.ResumedFromJournal > 0 {
+		fmt.Printf("Resumed from journal: %d\n", stats.ResumedFromJournal)
+	}
+
+	if len(stats.Plan) > 0 {
+		fmt.Println("\nPLAN (--dry-run, nothing was changed):")
+		fmt.Printf("  no-op:   %d\n", stats.PlanNoop)
+		fmt.Printf("  create:  %d\n", stats.PlanCreate)
+		fmt.Printf("  replace: %d\n", stats.PlanReplace)
+		for _, entry := range stats.Plan {
+			if entry.Action == planActionNoop {
+				continue
+			}
+			if entry.Action == planActionReplace {
+				fmt.Printf("  Row %d: %s '%s' in project '%s': %s (%s -> %s)\n", entry.Row, entry.SubjectType, entry.Subject, entry.Project, entry.Action, entry.PreviousRole, entry.Role)
+			} else {
+				fmt.Printf("  Row %d: %s '%s' in project '%s': %s '%s'\n", entry.Row, entry.SubjectType, entry.Subject, entry.Project, entry.Action, entry.Role)
+			}
+		}
+	}
 
 	if len(stats.Errors) > 0 {
 		fmt.Printf("\nErrors encountered (%d):\n", len(stats.Errors))
@@ -325,127 +887,355 @@ func printStats(stats *ProcessingStats) {
 	}
 }
 
-func main() {
-	// Define command-line flags
-	var (
-		projectFlag = flag.String("project", "", "Name of the project to add the user to (single user mode)")
-		emailFlag   = flag.String("email", "", "Email of the user to add (single user mode)")
-		roleFlag    = flag.String("role", "project-owner", "Role to assign to the user")
-		csvFlag     = flag.String("csv", "", "Path to CSV file for bulk processing")
-		dryRunFlag  = flag.Bool("dry-run", false, "Perform a dry run without making actual changes")
-		helpFlag    = flag.Bool("help", false, "Show help message")
-	)
-	flag.Parse()
-
-	// Show help if requested
-	if *helpFlag {
-		fmt.Println("Nobl9 User Role Manager")
-		fmt.Println("=======================")
-		fmt.Println()
-		fmt.Println("This tool assigns roles to users in Nobl9 projects.")
-		fmt.Println()
-		fmt.Println("MODES:")
-		fmt.Println("  Single User Mode: Use --project, --email, and --role flags")
-		fmt.Println("  Bulk CSV Mode: Use --csv flag with optional --role")
-		fmt.Println()
-		fmt.Println("FLAGS:")
-		flag.PrintDefaults()
-		fmt.Println()
-		fmt.Printf("Valid roles: %s\n", getValidRoles())
-		fmt.Println()
-		fmt.Println("CSV FORMAT:")
-		fmt.Println("  Required columns: 'App Short Name', 'User Email'")
-		fmt.Println("  Optional columns: 'User Exists' (Y/N)")
-		fmt.Println()
-		fmt.Println("ENVIRONMENT VARIABLES:")
-		fmt.Println("  NOBL9_CLIENT_ID: Your Nobl9 API Client ID")
-		fmt.Println("  NOBL9_CLIENT_SECRET: Your Nobl9 API Client Secret")
-		fmt.Println()
-		fmt.Println("EXAMPLES:")
-		fmt.Println("  Single user: ./add-user-role --project myproject --email user@example.com --role project-owner")
-		fmt.Println("  Bulk CSV:    ./add-user-role --csv projects.csv --role project-owner")
-		fmt.Println("  Dry run:     ./add-user-role --csv projects.csv --dry-run")
-		return
-	}
-
-	// Validate role
-	if !validRoles[*roleFlag] {
-		log.Fatalf("Error: Invalid role '%s'. Must be one of: %v", *roleFlag, getValidRoles())
-	}
-
-	// Determine mode: single user or bulk CSV
-	isSingleMode := *projectFlag != "" || *emailFlag != ""
-	isBulkMode := *csvFlag != ""
+// printStatsJSON writes stats (including its Results) as a single JSON object
+// to stdout, for --output=json.
+func printStatsJSON(stats *ProcessingStats) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stats)
+}
 
-	if isSingleMode && isBulkMode {
-		log.Fatal("Error: Cannot use single user mode flags (--project, --email) with bulk mode flag (--csv)")
+// printResultsJSONL writes one JSON object per row in stats.Results to
+// stdout, for --output=jsonl.
+func printResultsJSONL(stats *ProcessingStats) error {
+	enc := json.NewEncoder(os.Stdout)
+	for _, outcome := range stats.Results {
+		if err := enc.Encode(outcome); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rootFlags holds every root-command flag value, bound by newRootCmd and read
+// by runRoot. Kept as a single struct (rather than threading 23 individual
+// parameters) since almost every field is needed by both the validation and
+// the single-user/bulk dispatch below.
+type rootFlags struct {
+	project          string
+	email            string
+	role             string
+	csv              string
+	backend          string
+	dryRun           bool
+	userID           string
+	caseInsensitive  bool
+	refreshEvery     int
+	reconcile        bool
+	scope            string
+	confirmReconcile bool
+	workers          int
+	rate             float64
+	burst            int
+	output           string
+	subjectType      string
+	journal          string
+	inputFormat      string
+	logFormat        string
+	logLevel         string
+	auditLog         string
+	redactEmails     bool
+}
+
+// newRootCmd builds the add-user-role command: flag definitions, dynamic
+// shell-completion registration (see completion.go) and the `completion`
+// subcommand, with RunE delegating to runRoot.
+func newRootCmd() *cobra.Command {
+	var f rootFlags
+
+	cmd := &cobra.Command{
+		Use:   "add-user-role",
+		Short: "Assign Nobl9 project and organization roles to users and groups",
+		Long: fmt.Sprintf(`Nobl9 User Role Manager
+=======================
+
+This tool assigns roles to users and groups in Nobl9 projects.
+
+MODES:
+  Single User Mode: use --project, --email, and --role (add --subject-type=group to target a group instead of a user)
+  Bulk Mode:        use --csv with optional --role (CSV, YAML or JSON - see --input-format)
+
+Valid roles: %s
+
+CSV FORMAT:
+  Required columns: 'App Short Name', and 'User Email' (or 'Username' with --user-id=username)
+  Optional columns: 'User Exists' (Y/N), 'Role', 'Subject Type' ('user', 'group'; defaults to 'user')
+
+YAML/JSON FORMAT (--input-format=yaml|json, or a .yaml/.yml/.json --csv path):
+  A flat list of rows: [{project, email, username, role, subject_type, user_exists}, ...]
+  Same fields as the CSV columns above, lowercase and snake_case; project and an identifier are required per row.
+
+ENVIRONMENT VARIABLES:
+  NOBL9_CLIENT_ID: Your Nobl9 API Client ID
+  NOBL9_CLIENT_SECRET: Your Nobl9 API Client Secret`, getValidRoles()),
+		Example: `  Single user: add-user-role --project myproject --email user@example.com --role project-owner
+  Bulk CSV:    add-user-role --csv projects.csv --role project-owner
+  Dry run:     add-user-role --csv projects.csv --dry-run`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRoot(f)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&f.project, "project", "", "Name of the project to add the user to (single user mode)")
+	flags.StringVar(&f.email, "email", "", "Email of the user to add (single user mode)")
+	flags.StringVar(&f.role, "role", "project-owner", "Role to assign to the user")
+	flags.StringVar(&f.csv, "csv", "", "Path to an input file for bulk processing (CSV, YAML or JSON - see --input-format)")
+	flags.StringVar(&f.backend, "backend", backendAPI, fmt.Sprintf("Backend used for Nobl9 operations: only '%s' is implemented (this tool has never shelled out to sloctl for its own operations; see Backend's doc comment in backend.go)", backendAPI))
+	flags.BoolVar(&f.dryRun, "dry-run", false, "Perform a dry run without making actual changes")
+	flags.StringVar(&f.userID, "user-id", userIDModeEmail, "How users are identified in the CSV: 'email' or 'username' (username is not currently supported; the Nobl9 SDK has no lookup-by-username endpoint)")
+	flags.BoolVar(&f.caseInsensitive, "case-insensitive", false, "Lowercase user identifiers before lookup and duplicate detection (always applied for email)")
+	flags.IntVar(&f.refreshEvery, "refresh-bindings-every", 0, "Re-list existing role bindings from the server after every N assignments (0 disables periodic refresh)")
+	flags.BoolVar(&f.reconcile, "reconcile", false, "Treat the CSV as the desired state for --role, removing any existing binding of that role not represented in the CSV (bulk mode only)")
+	flags.StringVar(&f.scope, "scope", reconcileScopeProjectsInCSV, "Projects eligible for removal under --reconcile: 'project' (input must reference exactly one project), 'projects-in-csv' (any number of projects) or 'all'")
+	flags.BoolVar(&f.confirmReconcile, "confirm-reconcile", false, "Required alongside --reconcile (outside --dry-run) to actually delete role bindings")
+	flags.IntVar(&f.workers, "workers", 4, "Number of concurrent workers for bulk processing (bulk mode only)")
+	flags.Float64Var(&f.rate, "rate", 5, "Maximum API requests per second across all workers (bulk mode only; 0 disables rate limiting)")
+	flags.IntVar(&f.burst, "burst", 5, "Maximum burst size for --rate (bulk mode only)")
+	flags.StringVar(&f.output, "output", outputText, "Output format for results written to stdout: 'text', 'json' or 'jsonl'")
+	flags.StringVar(&f.subjectType, "subject-type", subjectTypeUser, "Type of subject identified by --email (single user mode): 'user' or 'group'")
+	flags.StringVar(&f.journal, "journal", "", "Path to a journal file recording per-row outcomes (bulk mode only); rerunning with the same CSV and journal resumes after an interruption instead of reprocessing finished rows")
+	flags.StringVar(&f.inputFormat, "input-format", inputFormatAuto, "Format of --csv: 'csv', 'yaml', 'json' or 'auto' (detect from file extension)")
+	flags.StringVar(&f.logFormat, "log-format", logFormatText, "Format for log messages written to stderr: 'text' or 'json'")
+	flags.StringVar(&f.logLevel, "log-level", "info", "Minimum log level written to stderr: 'debug', 'info', 'warn' or 'error'")
+	flags.StringVar(&f.auditLog, "audit-log", "", "Path to a JSON-lines audit log recording every completed assign-role/remove-role operation, for SIEM/compliance pipelines")
+	flags.BoolVar(&f.redactEmails, "redact-emails", false, "Hash subject emails (sha256) in the audit log instead of writing them in the clear")
+
+	registerCompletions(cmd)
+	cmd.AddCommand(newCompletionCmd())
+
+	return cmd
+}
+
+// runRoot validates f and dispatches to single-user or bulk mode. Returning
+// an error (rather than log.Fatal, as before the cobra migration) lets Execute
+// print it (without the usage text, since SilenceUsage is set) and main exit
+// with a non-zero code.
+func runRoot(f rootFlags) error {
+	if err := initLogger(f.logFormat, f.logLevel); err != nil {
+		return err
+	}
+
+	if f.backend != backendAPI {
+		return fmt.Errorf("invalid --backend '%s': only '%s' is implemented (this tool has never shelled out to sloctl for its own operations; see Backend's doc comment in backend.go)", f.backend, backendAPI)
+	}
+	if !validRoles[f.role] {
+		return fmt.Errorf("invalid role '%s'. Must be one of: %s", f.role, getValidRoles())
+	}
+	if f.userID != userIDModeEmail && f.userID != userIDModeUsername {
+		return fmt.Errorf("invalid --user-id '%s'. Must be 'email' or 'username'", f.userID)
+	}
+	if f.userID == userIDModeUsername {
+		return fmt.Errorf("--user-id=username is not currently supported: the Nobl9 SDK has no lookup-by-username endpoint to resolve it against; use --user-id=email")
+	}
+	if f.workers < 1 {
+		return fmt.Errorf("--workers must be at least 1, got %d", f.workers)
+	}
+	if f.rate < 0 {
+		return fmt.Errorf("--rate must not be negative, got %g", f.rate)
+	}
+	if f.output != outputText && f.output != outputJSON && f.output != outputJSONL {
+		return fmt.Errorf("invalid --output '%s'. Must be 'text', 'json' or 'jsonl'", f.output)
+	}
+	if f.subjectType != subjectTypeUser && f.subjectType != subjectTypeGroup {
+		return fmt.Errorf("invalid --subject-type '%s'. Must be 'user' or 'group' (service-account is not yet supported)", f.subjectType)
 	}
 
+	isSingleMode := f.project != "" || f.email != ""
+	isBulkMode := f.csv != ""
+
+	if isSingleMode && isBulkMode {
+		return fmt.Errorf("cannot use single user mode flags (--project, --email) with bulk mode flag (--csv)")
+	}
 	if !isSingleMode && !isBulkMode {
-		log.Fatal("Error: Must specify either single user mode (--project and --email) or bulk mode (--csv)")
+		return fmt.Errorf("must specify either single user mode (--project and --email) or bulk mode (--csv)")
 	}
 
-	// Single user mode validation
-	if isSingleMode {
-		if *projectFlag == "" || *emailFlag == "" {
-			log.Fatal("Error: Both --project and --email are required for single user mode")
+	if f.reconcile {
+		if !isBulkMode {
+			return fmt.Errorf("--reconcile requires bulk mode (--csv)")
 		}
+		if f.scope != reconcileScopeProject && f.scope != reconcileScopeProjectsInCSV && f.scope != reconcileScopeAll {
+			return fmt.Errorf("invalid --scope '%s'. Must be 'project', 'projects-in-csv' or 'all'", f.scope)
+		}
+		if !f.dryRun && !f.confirmReconcile {
+			return fmt.Errorf("--reconcile requires --confirm-reconcile (or --dry-run to preview without deleting)")
+		}
+	}
 
-		if !validateEmail(*emailFlag) {
-			log.Fatal("Error: Invalid email format")
+	if isSingleMode {
+		if f.email == "" {
+			return fmt.Errorf("--email is required for single user mode")
 		}
+		// Organization roles (see isOrganizationRole) apply at the org level,
+		// to a user or a group, and don't need a project.
+		if f.project == "" && !isOrganizationRole(f.role) {
+			return fmt.Errorf("--project is required for single user mode, unless --role is an organization-scoped role")
+		}
+		if f.subjectType == subjectTypeUser && f.userID != userIDModeUsername && !validateEmail(f.email) {
+			return fmt.Errorf("invalid email format")
+		}
+	}
+
+	singleUserID := f.email
+	if f.caseInsensitive || f.userID == userIDModeEmail {
+		singleUserID = strings.ToLower(singleUserID)
 	}
 
-	// Check environment variables
 	clientID := os.Getenv("NOBL9_CLIENT_ID")
 	clientSecret := os.Getenv("NOBL9_CLIENT_SECRET")
 	if clientID == "" || clientSecret == "" {
-		log.Fatal("Error: Environment variables NOBL9_CLIENT_ID and NOBL9_CLIENT_SECRET must be set")
+		return fmt.Errorf("environment variables NOBL9_CLIENT_ID and NOBL9_CLIENT_SECRET must be set")
 	}
-
-	// Set environment variables for the SDK
 	os.Setenv("NOBL9_CLIENT_ID", clientID)
 	os.Setenv("NOBL9_CLIENT_SECRET", clientSecret)
 
-	// Initialize client with timeout context
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	// Initialize client with timeout context, cancelled early on SIGINT so
+	// worker-pool goroutines stop dispatching new rows and exit cleanly
+	// instead of being killed mid-request.
+	sigCtx, stopSignal := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stopSignal()
+	ctx, cancel := context.WithTimeout(sigCtx, 5*time.Minute)
 	defer cancel()
 
-	// Initialize the Nobl9 client
 	client, err := sdk.DefaultClient()
 	if err != nil {
-		log.Fatalf("Error: Failed to create Nobl9 client: %v", err)
+		return fmt.Errorf("failed to create Nobl9 client: %v", err)
 	}
+	backend := NewAPIBackend(client)
 
-	if *dryRunFlag {
-		log.Println("DRY RUN MODE: No actual changes will be made")
+	if f.dryRun {
+		logger.Info("dry run mode: no actual changes will be made")
 	}
 
-	// Execute based on mode
-	if isSingleMode {
-		// Single user mode
-		log.Printf("Processing single user assignment: %s -> %s (%s)", *emailFlag, *projectFlag, *roleFlag)
+	// correlationID ties every audit record from this invocation together,
+	// the same nanosecond-uniqueness idiom applyRoleBinding already uses for
+	// role binding names.
+	correlationID := fmt.Sprintf("%x", time.Now().UnixNano())
 
-		err := assignProjectOwnerRole(ctx, client, *projectFlag, *emailFlag, *roleFlag, *dryRunFlag)
+	var audit *auditWriter
+	if f.auditLog != "" {
+		audit, err = openAuditLog(f.auditLog)
 		if err != nil {
-			log.Fatalf("Error: %v", err)
+			return err
 		}
+		defer audit.Close()
+	}
+
+	if isSingleMode {
+		return runSingleMode(ctx, backend, f, singleUserID, clientID, correlationID, audit)
+	}
+	return runBulkMode(ctx, backend, f, clientID, correlationID, audit)
+}
+
+// runSingleMode handles --project/--email mode: look up (or preview) and
+// apply one role binding, then print the outcome per --output. audit, if
+// non-nil, gets one record for the real (non-dry-run) assignment attempt.
+func runSingleMode(ctx context.Context, backend Backend, f rootFlags, singleUserID, actor, correlationID string, audit *auditWriter) error {
+	logger.Info("processing single assignment", "subject_type", f.subjectType, "subject", singleUserID, "project", f.project, "role", f.role)
+
+	cache, err := newRoleBindingCache(ctx, backend)
+	if err != nil {
+		logger.Warn("could not load existing role bindings, proceeding without a cache", "error", err)
+		cache = nil
+	}
 
-		fmt.Printf("Success: Assigned role '%s' to user '%s' in project '%s'\n", *roleFlag, *emailFlag, *projectFlag)
+	if f.dryRun {
+		plan := BuildPlan([]CSVRow{{AppShortName: f.project, UserEmail: singleUserID, Username: singleUserID, SubjectType: f.subjectType, OriginalRow: 1}}, f.role, f.userID, cache)
+		entry := plan[0]
+
+		switch f.output {
+		case outputJSON, outputJSONL:
+			if err := json.NewEncoder(os.Stdout).Encode(entry); err != nil {
+				return fmt.Errorf("failed to encode output: %v", err)
+			}
+		default:
+			if entry.Action == planActionReplace {
+				fmt.Printf("Plan: %s '%s' in project '%s': %s ('%s' -> '%s')\n", entry.SubjectType, entry.Subject, entry.Project, entry.Action, entry.PreviousRole, entry.Role)
+			} else {
+				fmt.Printf("Plan: %s '%s' in project '%s': %s '%s'\n", entry.SubjectType, entry.Subject, entry.Project, entry.Action, entry.Role)
+			}
+		}
+		return nil
+	}
+
+	bindingName, priorRole, assignErr := assignRoleBinding(ctx, backend, f.project, singleUserID, f.role, f.dryRun, f.userID, f.subjectType, cache, nil)
+	outcome := RowOutcome{Row: 1, Project: f.project, Email: singleUserID, Role: f.role, BindingName: bindingName}
+	if assignErr != nil {
+		outcome.Action = "failed"
+		outcome.Reason = assignErr.Error()
 	} else {
-		// Bulk CSV mode
-		log.Printf("Processing bulk assignment from CSV: %s (role: %s)", *csvFlag, *roleFlag)
+		outcome.Action = "assigned"
+		if priorRole != "" {
+			outcome.Reason = fmt.Sprintf("replaced prior role '%s'", priorRole)
+		}
+	}
 
-		stats, err := processBulkAssignment(ctx, client, *csvFlag, *roleFlag, *dryRunFlag)
-		if err != nil {
-			log.Fatalf("Error during bulk processing: %v", err)
+	if err := audit.record(auditRecord{
+		Actor:         actor,
+		Action:        auditActionAssignRole,
+		SubjectEmail:  redactEmail(singleUserID, f.redactEmails),
+		Project:       f.project,
+		Role:          f.role,
+		PriorRole:     priorRole,
+		Outcome:       outcome.Action,
+		Error:         outcome.Reason,
+		CorrelationID: correlationID,
+		Timestamp:     time.Now(),
+	}); err != nil {
+		logger.Warn("failed to write audit log entry", "error", err)
+	}
+
+	switch f.output {
+	case outputJSON, outputJSONL:
+		if err := json.NewEncoder(os.Stdout).Encode(outcome); err != nil {
+			return fmt.Errorf("failed to encode output: %v", err)
+		}
+	default:
+		if assignErr == nil {
+			fmt.Printf("Success: Assigned role '%s' to user '%s' in project '%s'\n", f.role, singleUserID, f.project)
 		}
+	}
 
-		printStats(stats)
+	return assignErr
+}
+
+// runBulkMode handles --csv mode: parse the input file, process every row
+// through processBulkAssignment, print the result per --output, and exit 1
+// if any row failed.
+func runBulkMode(ctx context.Context, backend Backend, f rootFlags, actor, correlationID string, audit *auditWriter) error {
+	logger.Info("processing bulk assignment", "input", f.csv, "role", f.role)
+
+	reconcile := reconcileOptions{enabled: f.reconcile, scope: f.scope, confirm: f.confirmReconcile}
+	concurrency := concurrencyOptions{workers: f.workers, rps: f.rate, burst: f.burst}
+	auditCtx := auditContext{actor: actor, correlationID: correlationID, writer: audit, redactEmails: f.redactEmails}
+	stats, err := processBulkAssignment(ctx, backend, f.csv, f.role, f.dryRun, f.userID, f.caseInsensitive, f.refreshEvery, reconcile, concurrency, f.journal, f.inputFormat, auditCtx)
+	if err != nil {
+		return fmt.Errorf("bulk processing failed: %v", err)
+	}
 
-		// Exit with error code if there were failures
-		if stats.Failed > 0 {
-			os.Exit(1)
+	switch f.output {
+	case outputJSON:
+		if err := printStatsJSON(stats); err != nil {
+			return fmt.Errorf("failed to encode output: %v", err)
 		}
+	case outputJSONL:
+		if err := printResultsJSONL(stats); err != nil {
+			return fmt.Errorf("failed to encode output: %v", err)
+		}
+	default:
+		printStats(stats)
+	}
+
+	if stats.Failed > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
 	}
 }
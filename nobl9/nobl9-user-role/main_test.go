@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	v1alphaRoleBinding "github.com/nobl9/nobl9-go/manifest/v1alpha/rolebinding"
 )
 
 func TestSanitizeName(t *testing.T) {
@@ -51,168 +57,6 @@ func TestValidateEmail(t *testing.T) {
 	}
 }
 
-func TestParseCSVFile(t *testing.T) {
-	dir := t.TempDir()
-
-	t.Run("valid file with project-name and user email", func(t *testing.T) {
-		f := filepath.Join(dir, "valid.csv")
-		if err := os.WriteFile(f, []byte("project-name,user email\np1,u1@x.com\np2,u2@x.com"), 0644); err != nil {
-			t.Fatal(err)
-		}
-		rows, err := parseCSVFile(f)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if len(rows) != 2 {
-			t.Fatalf("len(rows) = %d, want 2", len(rows))
-		}
-		if rows[0].ProjectName != "p1" || rows[0].UserEmail != "u1@x.com" {
-			t.Errorf("row0 = %+v", rows[0])
-		}
-		if rows[1].ProjectName != "p2" || rows[1].UserEmail != "u2@x.com" {
-			t.Errorf("row1 = %+v", rows[1])
-		}
-	})
-
-	t.Run("header variant project name", func(t *testing.T) {
-		f := filepath.Join(dir, "alt.csv")
-		if err := os.WriteFile(f, []byte("project name,user email\np1,u@x.com"), 0644); err != nil {
-			t.Fatal(err)
-		}
-		rows, err := parseCSVFile(f)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if len(rows) != 1 || rows[0].ProjectName != "p1" || rows[0].UserEmail != "u@x.com" {
-			t.Errorf("rows = %+v", rows)
-		}
-	})
-
-	t.Run("empty rows skipped", func(t *testing.T) {
-		f := filepath.Join(dir, "empty.csv")
-		if err := os.WriteFile(f, []byte("project-name,user email\np1,u@x.com\n,\n"), 0644); err != nil {
-			t.Fatal(err)
-		}
-		rows, err := parseCSVFile(f)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if len(rows) != 1 {
-			t.Errorf("len(rows) = %d, want 1 (empty row skipped)", len(rows))
-		}
-	})
-
-	t.Run("file not found", func(t *testing.T) {
-		_, err := parseCSVFile(filepath.Join(dir, "nonexistent.csv"))
-		if err == nil {
-			t.Fatal("expected error for missing file")
-		}
-		if !strings.Contains(err.Error(), "cannot open") {
-			t.Errorf("err = %v", err)
-		}
-	})
-
-	t.Run("header only", func(t *testing.T) {
-		f := filepath.Join(dir, "headeronly.csv")
-		if err := os.WriteFile(f, []byte("project-name,user email"), 0644); err != nil {
-			t.Fatal(err)
-		}
-		_, err := parseCSVFile(f)
-		if err == nil {
-			t.Fatal("expected error for header-only file")
-		}
-		if !strings.Contains(err.Error(), "at least a header row and one data row") {
-			t.Errorf("err = %v", err)
-		}
-	})
-
-	t.Run("missing required columns", func(t *testing.T) {
-		f := filepath.Join(dir, "badheader.csv")
-		if err := os.WriteFile(f, []byte("col1,col2\na,b"), 0644); err != nil {
-			t.Fatal(err)
-		}
-		_, err := parseCSVFile(f)
-		if err == nil {
-			t.Fatal("expected error for missing columns")
-		}
-		if !strings.Contains(err.Error(), "project-name") || !strings.Contains(err.Error(), "user email") {
-			t.Errorf("err = %v", err)
-		}
-	})
-}
-
-func TestValidateCSVFile(t *testing.T) {
-	dir := t.TempDir()
-	valid := filepath.Join(dir, "v.csv")
-	if err := os.WriteFile(valid, []byte("project-name,user email\np,u@x.com"), 0644); err != nil {
-		t.Fatal(err)
-	}
-
-	if err := validateCSVFile(valid); err != nil {
-		t.Errorf("validateCSVFile(valid) = %v", err)
-	}
-	if err := validateCSVFile(filepath.Join(dir, "nonexistent")); err == nil || !strings.Contains(err.Error(), "does not exist") {
-		t.Errorf("validateCSVFile(nonexistent) = %v", err)
-	}
-	if err := validateCSVFile(dir); err == nil || !strings.Contains(err.Error(), "directory") {
-		t.Errorf("validateCSVFile(dir) = %v", err)
-	}
-	empty := filepath.Join(dir, "empty.csv")
-	if err := os.WriteFile(empty, nil, 0644); err != nil {
-		t.Fatal(err)
-	}
-	if err := validateCSVFile(empty); err == nil || !strings.Contains(err.Error(), "empty") {
-		t.Errorf("validateCSVFile(empty) = %v", err)
-	}
-}
-
-func TestValidateCSVRows(t *testing.T) {
-	t.Run("project-level role requires project", func(t *testing.T) {
-		rows := []CSVRow{{ProjectName: "", UserEmail: "u@x.com"}}
-		invalid, ok := validateCSVRows(rows, false)
-		if ok {
-			t.Fatal("expected invalid")
-		}
-		if len(invalid) == 0 || !strings.Contains(invalid[0], "empty project name") {
-			t.Errorf("invalid = %v", invalid)
-		}
-	})
-	t.Run("empty email", func(t *testing.T) {
-		rows := []CSVRow{{ProjectName: "p", UserEmail: ""}}
-		invalid, ok := validateCSVRows(rows, false)
-		if ok {
-			t.Fatal("expected invalid")
-		}
-		if len(invalid) == 0 || !strings.Contains(invalid[0], "empty user email") {
-			t.Errorf("invalid = %v", invalid)
-		}
-	})
-	t.Run("invalid email", func(t *testing.T) {
-		rows := []CSVRow{{ProjectName: "p", UserEmail: "not-an-email"}}
-		invalid, ok := validateCSVRows(rows, false)
-		if ok {
-			t.Fatal("expected invalid")
-		}
-		if len(invalid) == 0 || !strings.Contains(invalid[0], "invalid email") {
-			t.Errorf("invalid = %v", invalid)
-		}
-	})
-	t.Run("org role allows empty project", func(t *testing.T) {
-		rows := []CSVRow{{ProjectName: "", UserEmail: "u@x.com"}}
-		invalid, ok := validateCSVRows(rows, true)
-		if !ok || len(invalid) != 0 {
-			t.Errorf("valid expected for org role; invalid = %v", invalid)
-		}
-	})
-	t.Run("all valid", func(t *testing.T) {
-		rows := []CSVRow{{ProjectName: "p", UserEmail: "u@x.com"}}
-		invalid, ok := validateCSVRows(rows, false)
-		if !ok || len(invalid) != 0 {
-			t.Errorf("invalid = %v, ok = %v", invalid, ok)
-		}
-	})
-}
-
 func TestIsOrganizationRole(t *testing.T) {
 	orgRoles := []string{"organization-admin", "organization-viewer", "viewer-status-page-manager"}
 	for _, r := range orgRoles {
@@ -253,34 +97,20 @@ func TestValidRoles(t *testing.T) {
 	}
 }
 
-func TestIsRetryable(t *testing.T) {
-	nonRetryable := []error{ErrUserNotFound, ErrAlreadyAssigned, ErrProjectNotFound, ErrRoleBindingExists, ErrValidation, ErrProjectRequired, context.Canceled, context.DeadlineExceeded}
-	for _, err := range nonRetryable {
-		if isRetryable(err) {
-			t.Errorf("isRetryable(%v) = true, want false", err)
-		}
-	}
-	if !isRetryable(errors.New("transient")) {
-		t.Error("isRetryable(transient) = false, want true")
-	}
-	if isRetryable(nil) {
-		t.Error("isRetryable(nil) = true, want false")
-	}
-}
-
 func TestPrintStats(t *testing.T) {
 	stats := &ProcessingStats{
-		TotalRows:           10,
-		Processed:           10,
-		Assigned:            6,
-		SkippedAlreadyOwner: 2,
+		TotalRows:            10,
+		Processed:            10,
+		Assigned:             6,
+		SkippedAlreadyOwner:  2,
 		SkippedUserNotExists: 1,
-		SkippedInvalidData:  1,
-		Failed:              0,
-		Errors:              []string{"Row 2: something"},
-		MissingUsers:        []string{"a@x.com"},
-		MissingProjects:     []string{"proj-x"},
-		AlreadyAssigned:     []string{"b@x.com -> p1"},
+		SkippedInvalidData:   1,
+		DuplicateRows:        1,
+		ConflictingRows:      1,
+		Failed:               0,
+		Removed:              2,
+		SkippedRemoval:       1,
+		Errors:               []string{"Row 2: something"},
 	}
 	r, w, err := os.Pipe()
 	if err != nil {
@@ -302,14 +132,20 @@ func TestPrintStats(t *testing.T) {
 	if !strings.Contains(outStr, "Successfully assigned: 6") {
 		t.Errorf("output missing assigned: %s", outStr)
 	}
-	if !strings.Contains(outStr, "a@x.com") {
-		t.Errorf("output missing missing user: %s", outStr)
+	if !strings.Contains(outStr, "Duplicate rows collapsed: 1") {
+		t.Errorf("output missing duplicate rows: %s", outStr)
+	}
+	if !strings.Contains(outStr, "Conflicting rows skipped: 1") {
+		t.Errorf("output missing conflicting rows: %s", outStr)
+	}
+	if !strings.Contains(outStr, "Removed (reconcile): 2") {
+		t.Errorf("output missing removed: %s", outStr)
 	}
-	if !strings.Contains(outStr, "proj-x") {
-		t.Errorf("output missing missing project: %s", outStr)
+	if !strings.Contains(outStr, "Skipped removal (reconcile): 1") {
+		t.Errorf("output missing skipped removal: %s", outStr)
 	}
-	if !strings.Contains(outStr, "b@x.com -> p1") {
-		t.Errorf("output missing already assigned: %s", outStr)
+	if !strings.Contains(outStr, "Row 2: something") {
+		t.Errorf("output missing error: %s", outStr)
 	}
 }
 
@@ -334,3 +170,890 @@ func TestPrintStatsErrorTruncation(t *testing.T) {
 		t.Errorf("expected truncation message: %s", outStr)
 	}
 }
+
+func TestCSVRowIdentifier(t *testing.T) {
+	row := CSVRow{UserEmail: "user@example.com", Username: "jdoe"}
+	if got := row.Identifier(userIDModeEmail); got != "user@example.com" {
+		t.Errorf("Identifier(email) = %q, want user@example.com", got)
+	}
+	if got := row.Identifier(userIDModeUsername); got != "jdoe" {
+		t.Errorf("Identifier(username) = %q, want jdoe", got)
+	}
+}
+
+func TestCSVRowResolvedSubjectType(t *testing.T) {
+	if got := (CSVRow{}).ResolvedSubjectType(); got != subjectTypeUser {
+		t.Errorf("ResolvedSubjectType() on blank column = %q, want %q", got, subjectTypeUser)
+	}
+	if got := (CSVRow{SubjectType: " Group "}).ResolvedSubjectType(); got != subjectTypeGroup {
+		t.Errorf("ResolvedSubjectType() = %q, want %q", got, subjectTypeGroup)
+	}
+}
+
+func TestParseCSVFileSubjectTypeColumn(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "subjects.csv")
+	content := "App Short Name,User Email,Subject Type\nmyapp,user@example.com,\nmyapp,platform-team,Group"
+	if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := parseCSVFile(f, userIDModeEmail, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("len(rows) = %d, want 2", len(rows))
+	}
+	if got := rows[0].ResolvedSubjectType(); got != subjectTypeUser {
+		t.Errorf("rows[0].ResolvedSubjectType() = %q, want %q", got, subjectTypeUser)
+	}
+	if got := rows[1].ResolvedSubjectType(); got != subjectTypeGroup {
+		t.Errorf("rows[1].ResolvedSubjectType() = %q, want %q", got, subjectTypeGroup)
+	}
+}
+
+func TestParseCSVFileUsernameMode(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "users.csv")
+	content := "App Short Name,Username\nmyapp,JDoe\nmyapp,asmith"
+	if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := parseCSVFile(f, userIDModeUsername, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("len(rows) = %d, want 2", len(rows))
+	}
+	if rows[0].Username != "jdoe" {
+		t.Errorf("rows[0].Username = %q, want lowercased jdoe", rows[0].Username)
+	}
+
+	if _, err := parseCSVFile(f, userIDModeEmail, false); err == nil {
+		t.Fatal("expected error when User Email column is missing in email mode")
+	}
+}
+
+func TestParseCSVFileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("file not found", func(t *testing.T) {
+		_, err := parseCSVFile(filepath.Join(dir, "nonexistent.csv"), userIDModeEmail, false)
+		if err == nil || !strings.Contains(err.Error(), "cannot open") {
+			t.Errorf("err = %v", err)
+		}
+	})
+
+	t.Run("header only", func(t *testing.T) {
+		f := filepath.Join(dir, "headeronly.csv")
+		if err := os.WriteFile(f, []byte("App Short Name,User Email"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := parseCSVFile(f, userIDModeEmail, false)
+		if err == nil || !strings.Contains(err.Error(), "at least a header row and one data row") {
+			t.Errorf("err = %v", err)
+		}
+	})
+
+	t.Run("missing required columns", func(t *testing.T) {
+		f := filepath.Join(dir, "badheader.csv")
+		if err := os.WriteFile(f, []byte("col1,col2\na,b"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := parseCSVFile(f, userIDModeEmail, false)
+		if err == nil || !strings.Contains(err.Error(), "App Short Name") {
+			t.Errorf("err = %v", err)
+		}
+	})
+}
+
+func TestDedupeRows(t *testing.T) {
+	t.Run("exact duplicates collapsed", func(t *testing.T) {
+		rows := []CSVRow{
+			{AppShortName: "p1", UserEmail: "u@x.com"},
+			{AppShortName: "p1", UserEmail: "u@x.com"},
+			{AppShortName: "p2", UserEmail: "u@x.com"},
+		}
+		kept, dupes, conflicts := dedupeRows(rows, "project-owner", userIDModeEmail)
+		if len(kept) != 2 {
+			t.Fatalf("len(kept) = %d, want 2", len(kept))
+		}
+		if dupes != 1 {
+			t.Errorf("dupes = %d, want 1", dupes)
+		}
+		if len(conflicts) != 0 {
+			t.Errorf("conflicts = %v, want none", conflicts)
+		}
+	})
+
+	t.Run("conflicting roles reported and skipped", func(t *testing.T) {
+		rows := []CSVRow{
+			{AppShortName: "p1", UserEmail: "u@x.com", Role: "project-viewer"},
+			{AppShortName: "p1", UserEmail: "u@x.com", Role: "project-owner"},
+		}
+		kept, dupes, conflicts := dedupeRows(rows, "project-owner", userIDModeEmail)
+		if len(kept) != 0 {
+			t.Fatalf("len(kept) = %d, want 0", len(kept))
+		}
+		if dupes != 0 {
+			t.Errorf("dupes = %d, want 0", dupes)
+		}
+		if len(conflicts) != 1 {
+			t.Fatalf("conflicts = %v, want 1 entry", conflicts)
+		}
+	})
+
+	t.Run("row without role uses default and conflicts with explicit row role", func(t *testing.T) {
+		rows := []CSVRow{
+			{AppShortName: "p1", UserEmail: "u@x.com"},
+			{AppShortName: "p1", UserEmail: "u@x.com", Role: "project-viewer"},
+		}
+		_, _, conflicts := dedupeRows(rows, "project-owner", userIDModeEmail)
+		if len(conflicts) != 1 {
+			t.Fatalf("conflicts = %v, want 1 entry", conflicts)
+		}
+	})
+}
+
+func TestReconcileRoleBindingsDryRun(t *testing.T) {
+	cache := &roleBindingCache{bindings: map[roleBindingKey]string{
+		{project: "p1", subject: "u1", subjectType: subjectTypeUser, role: "project-owner"}:  "rb-1",
+		{project: "p1", subject: "u2", subjectType: subjectTypeUser, role: "project-owner"}:  "rb-2",
+		{project: "p1", subject: "u1", subjectType: subjectTypeUser, role: "project-viewer"}: "rb-3", // different role, never touched
+	}}
+	desired := newDesiredSet()
+	desired.add(roleBindingKey{project: "p1", subject: "u1", subjectType: subjectTypeUser, role: "project-owner"})
+
+	removed, skipped := reconcileRoleBindings(context.Background(), nil, cache, "project-owner", desired, nil, true /* dryRun */, false, auditContext{})
+	if removed != 1 {
+		t.Errorf("removed = %d, want 1", removed)
+	}
+	if skipped != 0 {
+		t.Errorf("skipped = %d, want 0", skipped)
+	}
+	if len(cache.bindings) != 3 {
+		t.Errorf("dry run must not mutate the cache, len(bindings) = %d, want 3", len(cache.bindings))
+	}
+}
+
+func TestReconcileRoleBindingsRequiresConfirm(t *testing.T) {
+	cache := &roleBindingCache{bindings: map[roleBindingKey]string{
+		{project: "p1", subject: "u2", subjectType: subjectTypeUser, role: "project-owner"}: "rb-2",
+	}}
+
+	removed, skipped := reconcileRoleBindings(context.Background(), nil, cache, "project-owner", nil, nil, false /* dryRun */, false /* confirm */, auditContext{})
+	if removed != 0 {
+		t.Errorf("removed = %d, want 0 without --confirm-reconcile", removed)
+	}
+	if skipped != 1 {
+		t.Errorf("skipped = %d, want 1", skipped)
+	}
+}
+
+func TestDesiredSetNilIsReadOnlyNoOp(t *testing.T) {
+	var d *desiredSet
+	key := roleBindingKey{project: "p1", subject: "u1", subjectType: subjectTypeUser, role: "project-owner"}
+
+	d.add(key) // must not panic
+	if d.has(key) {
+		t.Error("nil desiredSet must report has() = false")
+	}
+}
+
+func TestDesiredSetAddHas(t *testing.T) {
+	d := newDesiredSet()
+	key := roleBindingKey{project: "p1", subject: "u1", subjectType: subjectTypeUser, role: "project-owner"}
+
+	if d.has(key) {
+		t.Error("has() = true before add")
+	}
+	d.add(key)
+	if !d.has(key) {
+		t.Error("has() = false after add")
+	}
+	other := roleBindingKey{project: "p1", subject: "u2", subjectType: subjectTypeUser, role: "project-owner"}
+	if d.has(other) {
+		t.Error("has() = true for a key that was never added")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := retryConfig{maxAttempts: 5, baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+
+	prev := time.Duration(0)
+	for attempt := 0; attempt < 4; attempt++ {
+		d := backoff(cfg, attempt)
+		if d < cfg.baseDelay {
+			t.Errorf("backoff(%d) = %s, want >= baseDelay %s", attempt, d, cfg.baseDelay)
+		}
+		if d > cfg.maxDelay+cfg.baseDelay {
+			t.Errorf("backoff(%d) = %s, exceeds maxDelay+jitter bound %s", attempt, d, cfg.maxDelay+cfg.baseDelay)
+		}
+		// Delay should grow (ignoring jitter noise) as attempts increase.
+		if attempt > 1 && d < prev/2 {
+			t.Errorf("backoff(%d) = %s unexpectedly small relative to backoff(%d) = %s", attempt, d, attempt-1, prev)
+		}
+		prev = d
+	}
+}
+
+func TestIsRetryableErr(t *testing.T) {
+	if isRetryableErr(nil) {
+		t.Error("isRetryableErr(nil) = true, want false")
+	}
+	if isRetryableErr(errors.New("boom")) {
+		t.Error("isRetryableErr(plain error) = true, want false")
+	}
+}
+
+func TestRowOutcomeJSONOmitsEmptyFields(t *testing.T) {
+	outcome := RowOutcome{Row: 1, Project: "p1", Email: "u@x.com", Role: "project-owner", Action: "assigned", BindingName: "rb-1"}
+	data, err := json.Marshal(outcome)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(data)
+	if strings.Contains(s, `"reason"`) {
+		t.Errorf("expected no 'reason' field for a successful outcome, got: %s", s)
+	}
+	for _, want := range []string{`"row":1`, `"project":"p1"`, `"action":"assigned"`, `"binding_name":"rb-1"`} {
+		if !strings.Contains(s, want) {
+			t.Errorf("expected %s in %s", want, s)
+		}
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	cache := &roleBindingCache{bindings: map[roleBindingKey]string{
+		{project: "p1", subject: "u1@x.com", subjectType: subjectTypeUser, role: "project-owner"}:  "rb-1",
+		{project: "p1", subject: "u2@x.com", subjectType: subjectTypeUser, role: "project-viewer"}: "rb-2",
+	}}
+	rows := []CSVRow{
+		{AppShortName: "p1", UserEmail: "u1@x.com"}, // already has project-owner: no-op
+		{AppShortName: "p1", UserEmail: "u2@x.com"}, // has project-viewer: replace
+		{AppShortName: "p1", UserEmail: "u3@x.com"}, // no existing binding: create
+	}
+
+	plan := BuildPlan(rows, "project-owner", userIDModeEmail, cache)
+	if len(plan) != 3 {
+		t.Fatalf("len(plan) = %d, want 3", len(plan))
+	}
+	if plan[0].Action != planActionNoop {
+		t.Errorf("plan[0].Action = %q, want %q", plan[0].Action, planActionNoop)
+	}
+	if plan[1].Action != planActionReplace || plan[1].PreviousRole != "project-viewer" {
+		t.Errorf("plan[1] = %+v, want action=%q previousRole=project-viewer", plan[1], planActionReplace)
+	}
+	if plan[2].Action != planActionCreate {
+		t.Errorf("plan[2].Action = %q, want %q", plan[2].Action, planActionCreate)
+	}
+}
+
+func TestBuildPlanNilCache(t *testing.T) {
+	rows := []CSVRow{{AppShortName: "p1", UserEmail: "u1@x.com"}}
+	plan := BuildPlan(rows, "project-owner", userIDModeEmail, nil)
+	if len(plan) != 1 || plan[0].Action != planActionCreate {
+		t.Errorf("plan = %+v, want single create entry", plan)
+	}
+}
+
+// fakeBackend is a minimal in-memory Backend used to drive runWorkerPool and
+// assignRoleBinding under -race without a real Nobl9 server. Every method that
+// touches its state locks mu, so the race detector is exercising roleBindingCache/desiredSet
+// concurrency (the actual shared state workers touch), not fakeBackend's own.
+type fakeBackend struct {
+	mu           sync.Mutex
+	bindings     []v1alphaRoleBinding.RoleBinding
+	deletedNames []string
+}
+
+func (b *fakeBackend) LookupUser(_ context.Context, userID, _ string) (string, error) {
+	return userID, nil
+}
+
+func (b *fakeBackend) ListRoleBindings(_ context.Context) ([]v1alphaRoleBinding.RoleBinding, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]v1alphaRoleBinding.RoleBinding, len(b.bindings))
+	copy(out, b.bindings)
+	return out, nil
+}
+
+func (b *fakeBackend) ApplyRoleBinding(_ context.Context, rb v1alphaRoleBinding.RoleBinding) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bindings = append(b.bindings, rb)
+	return nil
+}
+
+func (b *fakeBackend) DeleteRoleBinding(_ context.Context, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deletedNames = append(b.deletedNames, name)
+	kept := b.bindings[:0]
+	for _, rb := range b.bindings {
+		if rb.Metadata.Name != name {
+			kept = append(kept, rb)
+		}
+	}
+	b.bindings = kept
+	return nil
+}
+
+// TestRunWorkerPoolConcurrentRace drives runWorkerPool with several workers
+// over many rows sharing one roleBindingCache and desiredSet. It exists to be
+// run with -race: both are mutated from every worker goroutine, and this is
+// the only place that happens outside of a single-threaded aggregation step.
+func TestRunWorkerPoolConcurrentRace(t *testing.T) {
+	backend := &fakeBackend{}
+	rows := make([]CSVRow, 50)
+	for i := range rows {
+		rows[i] = CSVRow{AppShortName: "p1", UserEmail: fmt.Sprintf("user%d@x.com", i)}
+	}
+
+	cache, err := newRoleBindingCache(context.Background(), backend)
+	if err != nil {
+		t.Fatalf("newRoleBindingCache: %v", err)
+	}
+	desired := newDesiredSet()
+
+	results := runWorkerPool(context.Background(), backend, rows, "project-owner", false, userIDModeEmail, cache, desired, concurrencyOptions{workers: 8}, 10, nil)
+	if len(results) != len(rows) {
+		t.Fatalf("len(results) = %d, want %d", len(results), len(rows))
+	}
+	for i, res := range results {
+		if res.err != nil {
+			t.Errorf("row %d: unexpected error: %v", i, res.err)
+		}
+	}
+}
+
+// TestProcessBulkAssignmentDryRunPlanRowNumbers guards against stats.Plan
+// reporting row numbers relative to dispatchRows (the post-pre-validation
+// slice) instead of the original input file: row 1 here is skipped before
+// dispatch, so row 2's plan entry must still read "Row 2", not "Row 1".
+func TestProcessBulkAssignmentDryRunPlanRowNumbers(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "rows.csv")
+	content := "App Short Name,User Email\n,skip@x.com\np1,keep@x.com"
+	if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &fakeBackend{}
+	stats, err := processBulkAssignment(context.Background(), backend, f, "project-owner", true /* dryRun */, userIDModeEmail, false, 0, reconcileOptions{}, concurrencyOptions{workers: 1}, "", inputFormatAuto, auditContext{})
+	if err != nil {
+		t.Fatalf("processBulkAssignment: %v", err)
+	}
+
+	if len(stats.Plan) != 1 {
+		t.Fatalf("len(stats.Plan) = %d, want 1 (one row dispatched)", len(stats.Plan))
+	}
+	if stats.Plan[0].Row != 2 {
+		t.Errorf("stats.Plan[0].Row = %d, want 2 (original row number, not dispatch position)", stats.Plan[0].Row)
+	}
+}
+
+// TestProcessBulkAssignmentRowNumbersSurviveDedup guards against row numbers
+// drifting relative to the input file when dedupeRows collapses an earlier
+// row: row 2 here is a duplicate of row 1 and gets collapsed, so row 3's
+// plan entry must still read "Row 3", not "Row 2" (its position in the
+// post-dedup slice).
+func TestProcessBulkAssignmentRowNumbersSurviveDedup(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "rows.csv")
+	content := "App Short Name,User Email\np1,dup@x.com\np1,dup@x.com\np2,keep@x.com"
+	if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &fakeBackend{}
+	stats, err := processBulkAssignment(context.Background(), backend, f, "project-owner", true /* dryRun */, userIDModeEmail, false, 0, reconcileOptions{}, concurrencyOptions{workers: 1}, "", inputFormatAuto, auditContext{})
+	if err != nil {
+		t.Fatalf("processBulkAssignment: %v", err)
+	}
+
+	if stats.DuplicateRows != 1 {
+		t.Fatalf("stats.DuplicateRows = %d, want 1", stats.DuplicateRows)
+	}
+	if len(stats.Plan) != 2 {
+		t.Fatalf("len(stats.Plan) = %d, want 2", len(stats.Plan))
+	}
+	gotRows := []int{stats.Plan[0].Row, stats.Plan[1].Row}
+	wantRows := []int{1, 3}
+	if gotRows[0] != wantRows[0] || gotRows[1] != wantRows[1] {
+		t.Errorf("stats.Plan rows = %v, want %v (original file row numbers, not post-dedup position)", gotRows, wantRows)
+	}
+}
+
+// TestProcessBulkAssignmentReplacesExistingBindingOnRoleChange verifies that
+// a row whose subject already has a binding for a different role actually
+// supersedes it on a real (non-dry-run) run: BuildPlan's planActionReplace
+// is only a preview unless assignUserRole/assignGroupRole also delete the
+// prior binding, which is what this guards against regressing.
+func TestProcessBulkAssignmentReplacesExistingBindingOnRoleChange(t *testing.T) {
+	existing := v1alphaRoleBinding.New(
+		v1alphaRoleBinding.Metadata{Name: "existing-binding"},
+		v1alphaRoleBinding.Spec{User: ptr("u1@x.com"), RoleRef: "project-viewer", ProjectRef: "p1"},
+	)
+	backend := &fakeBackend{bindings: []v1alphaRoleBinding.RoleBinding{existing}}
+
+	dir := t.TempDir()
+	f := filepath.Join(dir, "rows.csv")
+	content := "App Short Name,User Email\np1,u1@x.com"
+	if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := processBulkAssignment(context.Background(), backend, f, "project-owner", false, userIDModeEmail, false, 0, reconcileOptions{}, concurrencyOptions{workers: 1}, "", inputFormatAuto, auditContext{})
+	if err != nil {
+		t.Fatalf("processBulkAssignment: %v", err)
+	}
+
+	if stats.Assigned != 1 {
+		t.Fatalf("stats.Assigned = %d, want 1", stats.Assigned)
+	}
+	if len(backend.deletedNames) != 1 || backend.deletedNames[0] != "existing-binding" {
+		t.Errorf("backend.deletedNames = %v, want [existing-binding] (prior binding must be deleted, not just left in place)", backend.deletedNames)
+	}
+	if len(stats.Results) != 1 || !strings.Contains(stats.Results[0].Reason, "project-viewer") {
+		t.Errorf("stats.Results[0].Reason = %q, want it to mention the replaced prior role", stats.Results[0].Reason)
+	}
+	if len(backend.bindings) != 1 || backend.bindings[0].Spec.RoleRef != "project-owner" {
+		t.Errorf("backend.bindings = %v, want exactly one binding for role project-owner", backend.bindings)
+	}
+}
+
+// TestProcessBulkAssignmentOrganizationRoleSkipsProjectRequirement verifies
+// that a row assigning an organization-scoped role is dispatched and
+// assigned even with no project, rather than skipped as invalid.
+func TestProcessBulkAssignmentOrganizationRoleSkipsProjectRequirement(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "rows.csv")
+	content := "App Short Name,User Email\n,org@x.com"
+	if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &fakeBackend{}
+	stats, err := processBulkAssignment(context.Background(), backend, f, "organization-admin", false, userIDModeEmail, false, 0, reconcileOptions{}, concurrencyOptions{workers: 1}, "", inputFormatAuto, auditContext{})
+	if err != nil {
+		t.Fatalf("processBulkAssignment: %v", err)
+	}
+	if stats.SkippedInvalidData != 0 {
+		t.Errorf("SkippedInvalidData = %d, want 0 (organization role should not require a project)", stats.SkippedInvalidData)
+	}
+	if stats.Assigned != 1 {
+		t.Errorf("Assigned = %d, want 1", stats.Assigned)
+	}
+}
+
+// TestProcessBulkAssignmentReconcileScopeProjectRejectsMultipleProjects
+// verifies --scope=project actually differs from --scope=projects-in-csv:
+// it refuses to reconcile an input that spans more than one project rather
+// than silently scoping to all of them.
+func TestProcessBulkAssignmentReconcileScopeProjectRejectsMultipleProjects(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "rows.csv")
+	content := "App Short Name,User Email\np1,u1@x.com\np2,u2@x.com"
+	if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &fakeBackend{}
+	reconcile := reconcileOptions{enabled: true, scope: reconcileScopeProject, confirm: true}
+	_, err := processBulkAssignment(context.Background(), backend, f, "project-owner", false, userIDModeEmail, false, 0, reconcile, concurrencyOptions{workers: 1}, "", inputFormatAuto, auditContext{})
+	if err == nil || !strings.Contains(err.Error(), "exactly one project") {
+		t.Errorf("err = %v, want an 'exactly one project' error", err)
+	}
+}
+
+func TestIsTerminalRegularFile(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "not-a-tty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if isTerminal(f) {
+		t.Error("isTerminal(regular file) = true, want false")
+	}
+}
+
+func TestPrintProgress(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	printProgress(w, time.Now().Add(-time.Second), 5, 10, 4, 1)
+	w.Close()
+	out, _ := io.ReadAll(r)
+	outStr := string(out)
+	if !strings.Contains(outStr, "5/10 rows") {
+		t.Errorf("output missing row count: %s", outStr)
+	}
+	if !strings.Contains(outStr, "4 ok, 1 failed") {
+		t.Errorf("output missing ok/failed counts: %s", outStr)
+	}
+}
+
+func TestReplayJournalMissingFileReturnsNil(t *testing.T) {
+	outcomes, err := ReplayJournal(filepath.Join(t.TempDir(), "nonexistent.jsonl"))
+	if err != nil {
+		t.Fatalf("ReplayJournal: %v", err)
+	}
+	if outcomes != nil {
+		t.Errorf("outcomes = %v, want nil for a missing journal", outcomes)
+	}
+}
+
+func TestReplayJournalAssignedAndSkippedResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	journal, err := openJournal(path, "hash1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []JournalEntry{
+		{RowIndex: 1, Project: "p1", Email: "u1@x.com", Role: "project-owner", Outcome: "assigned"},
+		{RowIndex: 2, Project: "p1", Email: "u2@x.com", Role: "project-owner", Outcome: "skipped", Error: "already assigned"},
+		{RowIndex: 3, Project: "p1", Email: "u3@x.com", Role: "project-owner", Outcome: "failed", Error: "boom"},
+	}
+	for _, e := range entries {
+		if err := journal.append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	outcomes, err := ReplayJournal(path)
+	if err != nil {
+		t.Fatalf("ReplayJournal: %v", err)
+	}
+	if len(outcomes) != 2 {
+		t.Fatalf("len(outcomes) = %d, want 2 (failed row 3 must not resume): %+v", len(outcomes), outcomes)
+	}
+	if outcomes[1].Action != "assigned" {
+		t.Errorf("outcomes[1].Action = %q, want assigned", outcomes[1].Action)
+	}
+	if outcomes[2].Action != "skipped" {
+		t.Errorf("outcomes[2].Action = %q, want skipped", outcomes[2].Action)
+	}
+	if _, ok := outcomes[3]; ok {
+		t.Error("row 3 (failed) must not be present in resumable outcomes")
+	}
+}
+
+// TestReplayJournalTruncatedLastLine simulates a process killed mid-write:
+// the journal's final line is a partial JSON object. Replay should recover
+// every complete entry before it rather than failing outright.
+func TestReplayJournalTruncatedLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	journal, err := openJournal(path, "hash1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.append(JournalEntry{RowIndex: 1, Project: "p1", Email: "u1@x.com", Role: "project-owner", Outcome: "assigned"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"row_index":2,"project":"p1","outcome":"assi`); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	outcomes, err := ReplayJournal(path)
+	if err != nil {
+		t.Fatalf("ReplayJournal must tolerate a truncated last line, got error: %v", err)
+	}
+	if len(outcomes) != 1 || outcomes[1].Action != "assigned" {
+		t.Errorf("outcomes = %+v, want just row 1 assigned", outcomes)
+	}
+}
+
+func TestJournalMatchesCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	journal, err := openJournal(path, "hash-a", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.append(JournalEntry{RowIndex: 1, Outcome: "assigned"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !journalMatchesCSV(path, "hash-a") {
+		t.Error("journalMatchesCSV(matching hash) = false, want true")
+	}
+	if journalMatchesCSV(path, "hash-b") {
+		t.Error("journalMatchesCSV(different hash) = true, want false")
+	}
+	if journalMatchesCSV(filepath.Join(t.TempDir(), "nonexistent.jsonl"), "hash-a") {
+		t.Error("journalMatchesCSV(missing file) = true, want false")
+	}
+}
+
+func TestHashCSVFileStableAndSensitive(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "rows.csv")
+	if err := os.WriteFile(f, []byte("App Short Name,User Email\np1,u@x.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h1, err := hashCSVFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := hashCSVFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 != h2 {
+		t.Errorf("hashCSVFile is not stable across calls: %q vs %q", h1, h2)
+	}
+
+	if err := os.WriteFile(f, []byte("App Short Name,User Email\np1,u@x.com\np2,u2@x.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h3, err := hashCSVFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 == h3 {
+		t.Error("hashCSVFile did not change after the CSV contents changed")
+	}
+}
+
+func TestDetectInputFormat(t *testing.T) {
+	tests := []struct {
+		path     string
+		explicit string
+		want     string
+		wantErr  bool
+	}{
+		{"rows.csv", "", inputFormatCSV, false},
+		{"rows.yaml", "", inputFormatYAML, false},
+		{"rows.yml", "", inputFormatYAML, false},
+		{"rows.json", "", inputFormatJSON, false},
+		{"rows.csv", inputFormatYAML, inputFormatYAML, false},
+		{"rows.txt", "", "", true},
+		{"rows.csv", "xml", "", true},
+	}
+	for _, tt := range tests {
+		got, err := detectInputFormat(tt.path, tt.explicit)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("detectInputFormat(%q, %q) = nil error, want error", tt.path, tt.explicit)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("detectInputFormat(%q, %q) = %v, want nil error", tt.path, tt.explicit, err)
+		}
+		if got != tt.want {
+			t.Errorf("detectInputFormat(%q, %q) = %q, want %q", tt.path, tt.explicit, got, tt.want)
+		}
+	}
+}
+
+func TestParseInputFileYAML(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "rows.yaml")
+	content := `
+- project: p1
+  email: u1@x.com
+  role: project-viewer
+- project: p2
+  email: u2@x.com
+`
+	if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := parseInputFile(f, userIDModeEmail, false, inputFormatAuto)
+	if err != nil {
+		t.Fatalf("parseInputFile: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("len(rows) = %d, want 2", len(rows))
+	}
+	if rows[0].AppShortName != "p1" || rows[0].UserEmail != "u1@x.com" || rows[0].Role != "project-viewer" {
+		t.Errorf("rows[0] = %+v", rows[0])
+	}
+	if rows[1].AppShortName != "p2" || rows[1].UserEmail != "u2@x.com" || rows[1].Role != "" {
+		t.Errorf("rows[1] = %+v", rows[1])
+	}
+}
+
+func TestParseInputFileJSON(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "rows.json")
+	content := `[{"project":"p1","email":"U1@X.COM","subject_type":"group"}]`
+	if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := parseInputFile(f, userIDModeEmail, false, inputFormatAuto)
+	if err != nil {
+		t.Fatalf("parseInputFile: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("len(rows) = %d, want 1", len(rows))
+	}
+	if rows[0].UserEmail != "u1@x.com" {
+		t.Errorf("UserEmail = %q, want lowercased u1@x.com", rows[0].UserEmail)
+	}
+	if rows[0].SubjectType != "group" {
+		t.Errorf("SubjectType = %q, want group", rows[0].SubjectType)
+	}
+}
+
+func TestParseInputFileEmptyYAMLErrors(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "rows.yaml")
+	if err := os.WriteFile(f, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseInputFile(f, userIDModeEmail, false, inputFormatAuto); err == nil {
+		t.Fatal("expected error for an empty row list")
+	}
+}
+
+func TestBuildPlanHonorsPerRowRole(t *testing.T) {
+	cache := &roleBindingCache{bindings: map[roleBindingKey]string{
+		{project: "p1", subject: "u1@x.com", subjectType: subjectTypeUser, role: "project-viewer"}: "rb-1",
+	}}
+	rows := []CSVRow{{AppShortName: "p1", UserEmail: "u1@x.com", Role: "project-viewer"}}
+
+	plan := BuildPlan(rows, "project-owner", userIDModeEmail, cache)
+	if len(plan) != 1 {
+		t.Fatalf("len(plan) = %d, want 1", len(plan))
+	}
+	if plan[0].Role != "project-viewer" {
+		t.Errorf("plan[0].Role = %q, want the row's own role project-viewer, not the CLI default", plan[0].Role)
+	}
+	if plan[0].Action != planActionNoop {
+		t.Errorf("plan[0].Action = %q, want %q", plan[0].Action, planActionNoop)
+	}
+}
+
+func TestRedactSecret(t *testing.T) {
+	t.Setenv("NOBL9_CLIENT_SECRET", "sekret-value")
+	got := redactSecret("request failed, secret=sekret-value sent")
+	if strings.Contains(got, "sekret-value") {
+		t.Errorf("redactSecret did not mask the secret: %q", got)
+	}
+	if !strings.Contains(got, "***") {
+		t.Errorf("redactSecret = %q, want a *** placeholder", got)
+	}
+}
+
+func TestRedactSecretNoopWhenUnset(t *testing.T) {
+	t.Setenv("NOBL9_CLIENT_SECRET", "")
+	got := redactSecret("nothing to redact here")
+	if got != "nothing to redact here" {
+		t.Errorf("redactSecret = %q, want unchanged input", got)
+	}
+}
+
+func TestRedactEmail(t *testing.T) {
+	if got := redactEmail("user@example.com", false); got != "user@example.com" {
+		t.Errorf("redactEmail(disabled) = %q, want email unchanged", got)
+	}
+
+	hashed := redactEmail("user@example.com", true)
+	if hashed == "user@example.com" {
+		t.Error("redactEmail(enabled) returned the email in the clear")
+	}
+	if !strings.HasPrefix(hashed, "sha256:") {
+		t.Errorf("redactEmail(enabled) = %q, want a sha256: prefix", hashed)
+	}
+	if redactEmail("user@example.com", true) != hashed {
+		t.Error("redactEmail is not stable across calls for the same input")
+	}
+	if redactEmail("other@example.com", true) == hashed {
+		t.Error("redactEmail produced the same hash for two different emails")
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := map[string]bool{
+		"debug": true, "info": true, "": true, "warn": true, "warning": true, "error": true, "bogus": false,
+	}
+	for level, wantOK := range tests {
+		_, err := parseLogLevel(level)
+		if wantOK && err != nil {
+			t.Errorf("parseLogLevel(%q) = %v, want nil error", level, err)
+		}
+		if !wantOK && err == nil {
+			t.Errorf("parseLogLevel(%q) = nil error, want an error", level)
+		}
+	}
+}
+
+func TestInitLoggerUnknownFormat(t *testing.T) {
+	if err := initLogger("xml", "info"); err == nil {
+		t.Error("initLogger with an unknown --log-format should return an error")
+	}
+}
+
+func TestAuditWriterRecordsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	aw, err := openAuditLog(path)
+	if err != nil {
+		t.Fatalf("openAuditLog: %v", err)
+	}
+
+	if err := aw.record(auditRecord{Actor: "client-1", Action: auditActionAssignRole, Project: "p1", Role: "project-owner", Outcome: "assigned", CorrelationID: "abc"}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if err := aw.record(auditRecord{Actor: "client-1", Action: auditActionRemoveRole, Project: "p1", Role: "project-owner", Outcome: "removed", CorrelationID: "abc"}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var rec auditRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if rec.Action != auditActionAssignRole || rec.Outcome != "assigned" || rec.CorrelationID != "abc" {
+		t.Errorf("rec = %+v, missing expected fields", rec)
+	}
+}
+
+func TestAuditWriterNilIsNoop(t *testing.T) {
+	var aw *auditWriter
+	if err := aw.record(auditRecord{Action: auditActionAssignRole}); err != nil {
+		t.Errorf("nil *auditWriter.record() = %v, want nil", err)
+	}
+	if err := aw.Close(); err != nil {
+		t.Errorf("nil *auditWriter.Close() = %v, want nil", err)
+	}
+}
+
+func TestAuditContextZeroValueIsNoop(t *testing.T) {
+	// Should not panic even though writer is nil.
+	var ac auditContext
+	ac.record(auditActionAssignRole, "user@example.com", "p1", "project-owner", "", "assigned", "")
+}
@@ -0,0 +1,63 @@
+package main
+
+// Actions a PlanEntry can describe.
+const (
+	planActionNoop    = "no-op"
+	planActionCreate  = "create"
+	planActionReplace = "replace"
+)
+
+// PlanEntry describes the change BuildPlan determined is needed for a single
+// row: whether the target role binding already exists (no-op), doesn't exist
+// yet (create), or exists for a different role (replace, with PreviousRole
+// set to the role it would replace). It's the structured form of --dry-run
+// output, surfaced via --output=json/jsonl and the plan summary in printStats.
+type PlanEntry struct {
+	Row          int    `json:"row"`
+	Project      string `json:"project"`
+	Subject      string `json:"subject"`
+	SubjectType  string `json:"subject_type"`
+	Role         string `json:"role"`
+	Action       string `json:"action"`
+	PreviousRole string `json:"previous_role,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// BuildPlan determines, for each row, whether assigning its role (the row's
+// own Role, if set, otherwise defaultRole) would be a no-op, a create, or a
+// replace of an existing binding for a different role. It consults cache
+// only; no network calls are made, so the plan reflects the state as of the
+// last refresh (a nil cache makes every row a create, since nothing is known
+// about existing bindings). rows is expected to be pre-validated (non-empty
+// project and subject); row numbers in the returned entries come from each
+// row's OriginalRow field, not its position within rows.
+func BuildPlan(rows []CSVRow, defaultRole, userIDMode string, cache *roleBindingCache) []PlanEntry {
+	plan := make([]PlanEntry, len(rows))
+	for i, row := range rows {
+		subject := row.Identifier(userIDMode)
+		subjectType := row.ResolvedSubjectType()
+		role := row.Role
+		if role == "" {
+			role = defaultRole
+		}
+		entry := PlanEntry{Row: row.OriginalRow, Project: row.AppShortName, Subject: subject, SubjectType: subjectType, Role: role}
+
+		switch {
+		case cache == nil:
+			entry.Action = planActionCreate
+			entry.Reason = "no existing role bindings loaded; assuming create"
+		case cache.has(row.AppShortName, subject, subjectType, role):
+			entry.Action = planActionNoop
+		default:
+			if previousRole, _, ok := cache.findBySubject(row.AppShortName, subject, subjectType); ok {
+				entry.Action = planActionReplace
+				entry.PreviousRole = previousRole
+			} else {
+				entry.Action = planActionCreate
+			}
+		}
+
+		plan[i] = entry
+	}
+	return plan
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/nobl9/nobl9-go/sdk"
+)
+
+// retryConfig bounds the exponential backoff applied around transient Nobl9
+// API calls (HTTP 429 and 5xx responses).
+type retryConfig struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// defaultRetryConfig is used for every retried call: five attempts with a
+// half-second base delay covers typical rate-limit windows without making a
+// failing row block the rest of the run for too long.
+var defaultRetryConfig = retryConfig{
+	maxAttempts: 5,
+	baseDelay:   500 * time.Millisecond,
+	maxDelay:    30 * time.Second,
+}
+
+// isRetryableErr reports whether err represents a transient condition (HTTP
+// 429 or 5xx) that's worth retrying.
+func isRetryableErr(err error) bool {
+	var httpErr *sdk.HTTPError
+	if !errors.As(err, &httpErr) {
+		return false
+	}
+	return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= http.StatusInternalServerError
+}
+
+// backoff computes the exponential delay for the given 0-indexed attempt
+// number, capped at cfg.maxDelay, plus up to cfg.baseDelay of random jitter
+// added on top of the cap.
+func backoff(cfg retryConfig, attempt int) time.Duration {
+	delay := cfg.baseDelay << uint(attempt)
+	if delay <= 0 || delay > cfg.maxDelay {
+		delay = cfg.maxDelay
+	}
+	jitter := time.Duration(rand.Int63n(int64(cfg.baseDelay) + 1))
+	return delay + jitter
+}
+
+// retryErr calls fn, retrying with exponential backoff on transient errors up
+// to cfg.maxAttempts. Non-retryable errors are returned immediately without
+// waiting. call names the backend operation being retried (e.g. "lookup
+// user"), purely for the latency/attempt-count log line emitted once fn's
+// outcome is known.
+func retryErr(ctx context.Context, cfg retryConfig, call string, fn func() error) error {
+	start := time.Now()
+	var err error
+	attempt := 0
+	for ; attempt < cfg.maxAttempts; attempt++ {
+		if err = fn(); err == nil {
+			logger.Debug("backend call succeeded", "call", call, "attempts", attempt+1, "latency", time.Since(start))
+			return nil
+		}
+		if !isRetryableErr(err) || attempt == cfg.maxAttempts-1 {
+			break
+		}
+
+		delay := backoff(cfg, attempt)
+		logger.Warn("transient error, retrying", "call", call, "attempt", attempt+1, "max_attempts", cfg.maxAttempts, "delay", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	logger.Error("backend call failed", "call", call, "attempts", attempt+1, "latency", time.Since(start), "error", err)
+	return fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// roleBindingKey identifies a single role binding by the project, subject and
+// role it covers. subject is a resolved user ID or a group name, depending on
+// subjectType (see the subjectType* constants in main.go).
+type roleBindingKey struct {
+	project     string
+	subject     string
+	subjectType string
+	role        string
+}
+
+// roleBindingCache mirrors the role bindings that exist on the Nobl9 server,
+// so assignRoleBinding can tell whether a row is already satisfied
+// without issuing a server round-trip for every row. The map value is the
+// binding's manifest name, needed to delete it during --reconcile. mu guards
+// bindings, since workers in the bulk worker pool consult and update the
+// cache concurrently.
+type roleBindingCache struct {
+	mu       sync.RWMutex
+	bindings map[roleBindingKey]string
+}
+
+// newRoleBindingCache lists every RoleBinding on the server and indexes it by
+// (project, subject, subjectType, role).
+func newRoleBindingCache(ctx context.Context, backend Backend) (*roleBindingCache, error) {
+	c := &roleBindingCache{}
+	if err := c.refresh(ctx, backend); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// refresh re-lists all role bindings from the server, replacing the cache
+// contents. Called periodically during a bulk run (see --refresh-bindings-every)
+// to pick up out-of-band changes made outside this run.
+func (c *roleBindingCache) refresh(ctx context.Context, backend Backend) error {
+	rbs, err := backend.ListRoleBindings(ctx)
+	if err != nil {
+		return err
+	}
+
+	bindings := make(map[roleBindingKey]string, len(rbs))
+	for _, rb := range rbs {
+		var subject, subjectType string
+		switch {
+		case rb.Spec.User != nil:
+			subject, subjectType = *rb.Spec.User, subjectTypeUser
+		case rb.Spec.GroupRef != nil:
+			subject, subjectType = *rb.Spec.GroupRef, subjectTypeGroup
+		default:
+			continue
+		}
+
+		bindings[roleBindingKey{
+			project:     rb.Spec.ProjectRef,
+			subject:     subject,
+			subjectType: subjectType,
+			role:        rb.Spec.RoleRef,
+		}] = rb.Metadata.Name
+	}
+
+	c.mu.Lock()
+	c.bindings = bindings
+	c.mu.Unlock()
+	logger.Info("loaded existing role bindings", "count", len(bindings))
+	return nil
+}
+
+// has reports whether the given project/subject/role combination is already bound.
+func (c *roleBindingCache) has(project, subject, subjectType, role string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.bindings[roleBindingKey{project: project, subject: subject, subjectType: subjectType, role: role}]
+	return ok
+}
+
+// record marks a binding as now existing, so later rows in the same run see
+// it without waiting for the next refresh.
+func (c *roleBindingCache) record(project, subject, subjectType, role, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.bindings == nil {
+		c.bindings = make(map[roleBindingKey]string)
+	}
+	c.bindings[roleBindingKey{project: project, subject: subject, subjectType: subjectType, role: role}] = name
+}
+
+// findBySubject returns the role and binding name of any existing binding for
+// (project, subject, subjectType), regardless of which role it grants. It's
+// used by BuildPlan to tell a create (no existing binding) apart from a
+// replace (a binding exists, but for a different role). Nobl9 only allows one
+// binding per (project, subject) pair, so the first match is authoritative.
+func (c *roleBindingCache) findBySubject(project, subject, subjectType string) (role, name string, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for key, n := range c.bindings {
+		if key.project == project && key.subject == subject && key.subjectType == subjectType {
+			return key.role, n, true
+		}
+	}
+	return "", "", false
+}
+
+// remove forgets a binding that has been deleted on the server.
+func (c *roleBindingCache) remove(key roleBindingKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.bindings, key)
+}
+
+// snapshot returns a copy of the cached bindings, safe to range over without
+// holding c.mu for the duration (used by reconcileRoleBindings, which may
+// itself call c.remove while iterating).
+func (c *roleBindingCache) snapshot() map[roleBindingKey]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[roleBindingKey]string, len(c.bindings))
+	for k, v := range c.bindings {
+		out[k] = v
+	}
+	return out
+}
+
+// desiredSet tracks the (project, user, role) bindings the current run wants
+// to keep, built concurrently by the bulk worker pool and consulted by
+// reconcileRoleBindings once every row has been processed. A nil *desiredSet
+// means --reconcile is disabled; add and has are then no-ops/false.
+type desiredSet struct {
+	mu   sync.Mutex
+	keys map[roleBindingKey]bool
+}
+
+// newDesiredSet returns an empty desiredSet ready for concurrent use.
+func newDesiredSet() *desiredSet {
+	return &desiredSet{keys: make(map[roleBindingKey]bool)}
+}
+
+// add records key as wanted.
+func (d *desiredSet) add(key roleBindingKey) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.keys[key] = true
+	d.mu.Unlock()
+}
+
+// has reports whether key was previously added.
+func (d *desiredSet) has(key roleBindingKey) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.keys[key]
+}
+
+// Scopes supported by --scope, controlling which projects are eligible for
+// removal during --reconcile. reconcileScopeProject behaves exactly like
+// reconcileScopeProjectsInCSV except that processBulkAssignment rejects it,
+// after the rows' assignments are applied and before any binding is removed,
+// if the input references more than one project - it exists for
+// CSVs that are understood to be single-project, to fail loudly if that
+// assumption is ever violated instead of silently reconciling across
+// projects the caller didn't expect.
+const (
+	reconcileScopeProject       = "project"
+	reconcileScopeProjectsInCSV = "projects-in-csv"
+	reconcileScopeAll           = "all"
+)
+
+// reconcileRoleBindings removes existing bindings for role that are not part
+// of desired, restricted to the projects in scope (nil scope means no
+// restriction, used for reconcileScopeAll). Nothing is deleted unless confirm
+// is true; dryRun (which implies confirm is irrelevant) only logs intent.
+func reconcileRoleBindings(
+	ctx context.Context,
+	backend Backend,
+	cache *roleBindingCache,
+	role string,
+	desired *desiredSet,
+	scopeProjects map[string]bool,
+	dryRun, confirm bool,
+	audit auditContext,
+) (removed, skippedRemoval int) {
+	for key, name := range cache.snapshot() {
+		if key.role != role {
+			continue
+		}
+		if scopeProjects != nil && !scopeProjects[key.project] {
+			continue
+		}
+		if desired.has(key) {
+			continue
+		}
+
+		if dryRun {
+			logger.Info("dry run: would remove role binding", "subject_type", key.subjectType, "subject", key.subject, "project", key.project, "role", role)
+			removed++
+			continue
+		}
+
+		if !confirm {
+			logger.Info("reconcile: would remove role binding, pass --confirm-reconcile to apply", "subject_type", key.subjectType, "subject", key.subject, "project", key.project, "role", role)
+			skippedRemoval++
+			continue
+		}
+
+		if err := backend.DeleteRoleBinding(ctx, name); err != nil {
+			logger.Error("failed to remove role binding", "subject_type", key.subjectType, "subject", key.subject, "project", key.project, "error", err)
+			audit.record(auditActionRemoveRole, key.subject, key.project, role, "", "failed", err.Error())
+			skippedRemoval++
+			continue
+		}
+
+		logger.Info("removed role binding", "subject_type", key.subjectType, "subject", key.subject, "project", key.project, "role", role)
+		audit.record(auditActionRemoveRole, key.subject, key.project, role, "", "removed", "")
+		cache.remove(key)
+		removed++
+	}
+	return removed, skippedRemoval
+}